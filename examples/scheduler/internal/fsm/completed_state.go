@@ -2,21 +2,21 @@ package fsm
 
 import (
 	"context"
-	"fmt"
 	gofsm "github.com/rluders/gofsm/fsm"
-	"scheduler/internal/domain"
 )
 
-type CompletedState struct {
-	scan *domain.Scan
-}
+type CompletedState struct{}
 
 func (s *CompletedState) Name() string { return StateCompleted }
 func (s *CompletedState) OnEnter(ctx context.Context, e gofsm.Event) error {
-	s.scan.Status = StateCompleted
-	return nil
+	scan, err := scanFrom(ctx)
+	if err != nil {
+		return err
+	}
+	scan.Status = StateCompleted
+	for _, job := range scan.Jobs {
+		job.State = StateCompleted
+	}
+	return gofsm.SaveData(ctx, scan)
 }
 func (s *CompletedState) OnExit(ctx context.Context, e gofsm.Event) error { return nil }
-func (s *CompletedState) HandleEvent(ctx context.Context, e gofsm.Event) (gofsm.Transition, error) {
-	return gofsm.Transition{}, fmt.Errorf("completed: no transitions allowed")
-}
@@ -2,24 +2,18 @@ package fsm
 
 import (
 	"context"
-	"fmt"
 	gofsm "github.com/rluders/gofsm/fsm"
-	"scheduler/internal/domain"
 )
 
-type PendingState struct {
-	scan *domain.Scan
-}
+type PendingState struct{}
 
 func (s *PendingState) Name() string { return StatePending }
 func (s *PendingState) OnEnter(ctx context.Context, e gofsm.Event) error {
-	s.scan.Status = StatePending
-	return nil
-}
-func (s *PendingState) OnExit(ctx context.Context, e gofsm.Event) error { return nil }
-func (s *PendingState) HandleEvent(ctx context.Context, e gofsm.Event) (gofsm.Transition, error) {
-	if e.Name() == EventStartScan {
-		return gofsm.Transition{NextState: StateRunning}, nil
+	scan, err := scanFrom(ctx)
+	if err != nil {
+		return err
 	}
-	return gofsm.Transition{}, fmt.Errorf("pending: unexpected event '%s'", e.Name())
+	scan.Status = StatePending
+	return gofsm.SaveData(ctx, scan)
 }
+func (s *PendingState) OnExit(ctx context.Context, e gofsm.Event) error { return nil }
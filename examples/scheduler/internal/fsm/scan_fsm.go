@@ -19,14 +19,10 @@ const (
 	EventAllJobsCompleted = "all_jobs_completed"
 )
 
-type ScanFSM struct {
-	scan *domain.Scan
-}
-
-func NewScanFSM(scan *domain.Scan, storage gofsm.LockableStorage, logger gofsm.Logger) (*gofsm.FSM, error) {
-	if scan == nil {
-		return nil, errors.New("fsm: scan is required")
-	}
+// NewScanFSM builds the scan state machine. It is created once and shared by
+// every scan: the scan being handled is passed to Trigger with
+// gofsm.WithEntity, or loaded from the extended state stored with it.
+func NewScanFSM(storage gofsm.LockableStorage, logger gofsm.Logger) (*gofsm.FSM, error) {
 	if storage == nil {
 		return nil, errors.New("fsm: StateStorage is required")
 	}
@@ -34,22 +30,49 @@ func NewScanFSM(scan *domain.Scan, storage gofsm.LockableStorage, logger gofsm.L
 		logger = &gofsm.DefaultLogger{}
 	}
 
-	states := []gofsm.State{
-		&PendingState{scan},
-		&RunningState{scan},
-		&CompletedState{scan},
-	}
+	def := gofsm.NewDefinition().
+		Add(
+			&PendingState{},
+			&RunningState{},
+			&CompletedState{},
+		).
+		From(StatePending).On(EventStartScan).To(StateRunning).
+		From(StateRunning).On(EventAllJobsCompleted).To(StateCompleted).
+		Final(StateCompleted)
 
-	return gofsm.NewFSM(states,
+	return gofsm.NewFSM(def.States(),
 		gofsm.WithStateStorage(storage), // TODO I want to make all storage locked
 		gofsm.WithAutoLock(storage, gofsm.LockRetryConfig{
 			MaxRetries:      5,
 			BackoffInterval: 200 * time.Millisecond,
 		}, lockFailureHandler),
+		gofsm.WithEntityLoader(loadScan),
 		gofsm.WithLogger(logger),
+		gofsm.WithInitialState(StatePending),
 	)
 }
 
+// loadScan rebuilds the scan from the extended state stored with its state.
+func loadScan(ctx context.Context, scanID string) (any, error) {
+	scan, err := gofsm.LoadData[*domain.Scan](ctx)
+	if err != nil {
+		return nil, err
+	}
+	if scan == nil {
+		scan = &domain.Scan{ID: scanID}
+	}
+	return scan, nil
+}
+
+// scanFrom returns the scan being handled.
+func scanFrom(ctx context.Context) (*domain.Scan, error) {
+	scan, ok := gofsm.Entity[*domain.Scan](ctx)
+	if !ok {
+		return nil, errors.New("fsm: no scan in context")
+	}
+	return scan, nil
+}
+
 func lockFailureHandler(ctx context.Context, entityID string, event gofsm.Event) {
 	slog.Warn("lock failure", "entity", entityID, "event", event.Name())
 }
@@ -3,6 +3,7 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/redis/go-redis/v9"
 	"log/slog"
 	"math/rand"
@@ -57,6 +58,11 @@ func StartConsumer(ctx context.Context) error {
 	})
 	stateStorage := redisstore.NewRedisStorage(redisClient)
 
+	scanFSM, err := fsm.NewScanFSM(stateStorage, &gofsm.DefaultLogger{})
+	if err != nil {
+		return err
+	}
+
 	slog.Info("scheduler: listening for scan events...", "broker", broker, "group", groupID)
 
 	for {
@@ -87,20 +93,17 @@ func StartConsumer(ctx context.Context) error {
 			})
 		}
 
-		fsmInstance, err := fsm.NewScanFSM(scan, stateStorage, &gofsm.DefaultLogger{})
-		if err != nil {
-			slog.Error("failed to create FSM", "error", err)
+		scanCtx := gofsm.WithEntity(ctx, scan)
+
+		if err := scanFSM.Init(scanCtx, scan.ID); err != nil && !errors.Is(err, gofsm.ErrEntityExists) {
+			slog.Error("failed to initialize FSM state", "error", err)
 			continue
 		}
 
-		state, err := fsmInstance.CurrentState(ctx, scan.ID)
+		state, err := scanFSM.CurrentState(ctx, scan.ID)
 		if err != nil {
-			slog.Info("initializing FSM state", "scan_id", scan.ID)
-			if err := stateStorage.SetState(ctx, scan.ID, fsm.StatePending); err != nil {
-				slog.Error("failed to set initial state", "error", err)
-				continue
-			}
-			state = fsm.StatePending
+			slog.Error("failed to read FSM state", "error", err)
+			continue
 		}
 
 		if state == fsm.StateRunning || state == fsm.StateCompleted {
@@ -108,28 +111,23 @@ func StartConsumer(ctx context.Context) error {
 			continue
 		}
 
-		if err := fsmInstance.Trigger(ctx, scan.ID, gofsm.NewBasicEvent(fsm.EventStartScan, nil)); err != nil {
+		if err := scanFSM.Trigger(scanCtx, scan.ID, gofsm.NewBasicEvent(fsm.EventStartScan, nil)); err != nil {
 			slog.Error("failed to trigger start_scan", "error", err)
 			continue
 		}
 
-		go func(s *domain.Scan) {
+		go func(scanID string, jobs []*domain.ScanJob) {
 			time.Sleep(time.Duration(rand.Intn(30)) * time.Second)
-			for _, job := range s.Jobs {
-				job.State = fsm.StateCompleted
-				slog.Info("sending scan job", "id", job.ID, "scan_id", job.ScanID, "state", job.State)
+			for _, job := range jobs {
+				slog.Info("sending scan job", "id", job.ID, "scan_id", job.ScanID)
 			}
 
-			slog.Info("triggering all_jobs_completed", "scan_id", s.ID)
+			slog.Info("triggering all_jobs_completed", "scan_id", scanID)
 
-			fsmInstance, err := fsm.NewScanFSM(s, stateStorage, &gofsm.DefaultLogger{})
-			if err != nil {
-				slog.Error("fsm rehydrate failed", "error", err)
-				return
-			}
-			if err := fsmInstance.Trigger(ctx, s.ID, gofsm.NewBasicEvent(fsm.EventAllJobsCompleted, nil)); err != nil {
+			// The scan is loaded from the stored extended state.
+			if err := scanFSM.Trigger(ctx, scanID, gofsm.NewBasicEvent(fsm.EventAllJobsCompleted, nil)); err != nil {
 				slog.Error("fsm all_jobs_completed failed", "error", err)
 			}
-		}(scan)
+		}(scan.ID, scan.Jobs)
 	}
 }
@@ -0,0 +1,114 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type FakeHistoryStore struct {
+	records []TransitionRecord
+	err     error
+}
+
+func (s *FakeHistoryStore) Append(ctx context.Context, record TransitionRecord) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *FakeHistoryStore) History(ctx context.Context, entityID string) ([]TransitionRecord, error) {
+	var records []TransitionRecord
+	for _, r := range s.records {
+		if r.EntityID == entityID {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+func TestFSM_AuditTrail(t *testing.T) {
+	ctx := WithActor(context.Background(), "alice")
+	entityID := "entity-audit"
+	history := &FakeHistoryStore{}
+
+	def := NewDefinition().
+		Composite("scan", "running", "done").
+		From("pending").On("start_scan").To("scan").
+		From("running").On("all_jobs_completed").To("done").
+		From("scan").OnDone().To("completed").
+		Final("done", "completed")
+
+	fsm, err := NewFSM(def.States(),
+		WithStateStorage(NewFakeStorage()),
+		WithLogger(&MockLogger{}),
+		WithInitialState("pending"),
+		WithHistoryStore(history),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error creating FSM: %v", err)
+	}
+
+	if err := fsm.Init(ctx, entityID); err != nil {
+		t.Fatalf("unexpected error on Init: %v", err)
+	}
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("start_scan", "10.0.0.1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("all_jobs_completed", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Rejected events leave no trace.
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("start_scan", nil)); !errors.Is(err, ErrEntityCompleted) {
+		t.Fatalf("expected ErrEntityCompleted, got %v", err)
+	}
+
+	records, err := fsm.History(ctx, entityID)
+	if err != nil {
+		t.Fatalf("unexpected error reading history: %v", err)
+	}
+
+	want := []struct{ event, from, to string }{
+		{InitEvent, "", "pending"},
+		{"start_scan", "pending", "scan/running"},
+		{"all_jobs_completed", "scan/running", "scan/done"},
+		{DoneEvent("scan"), "scan/done", "completed"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("expected %d records, got %+v", len(want), records)
+	}
+	for i, w := range want {
+		r := records[i]
+		if r.Event != w.event || r.From != w.from || r.To != w.to || r.Actor != "alice" || r.Timestamp.IsZero() {
+			t.Errorf("record %d: expected %s %s → %s by alice, got %+v", i, w.event, w.from, w.to, r)
+		}
+	}
+	if records[1].Payload != "10.0.0.1" {
+		t.Errorf("expected payload to be recorded, got %v", records[1].Payload)
+	}
+}
+
+func TestFSM_AuditTrailStoreFailure(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-audit-fail"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "pending")
+
+	fsm, err := NewFSM(NewDefinition().From("pending").On("go").To("done").Final("done").States(),
+		WithStateStorage(storage),
+		WithLogger(&MockLogger{}),
+		WithHistoryStore(&FakeHistoryStore{err: errors.New("unavailable")}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error creating FSM: %v", err)
+	}
+
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("go", nil)); err != nil {
+		t.Fatalf("expected transition to succeed despite history failure, got %v", err)
+	}
+	if state, _ := storage.GetState(ctx, entityID); state != "done" {
+		t.Errorf("expected state done, got %s", state)
+	}
+}
@@ -0,0 +1,73 @@
+package fsm
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// BackoffStrategy computes how long to wait before retrying to acquire a
+// lock. attempt is the zero-based index of the attempt that just failed, and
+// previous is the delay returned for the previous attempt.
+type BackoffStrategy interface {
+	Delay(attempt int, previous time.Duration) time.Duration
+}
+
+// ConstantBackoff waits the same interval between attempts.
+type ConstantBackoff struct {
+	Interval time.Duration
+}
+
+func (b ConstantBackoff) Delay(attempt int, previous time.Duration) time.Duration {
+	return b.Interval
+}
+
+// ExponentialBackoff doubles the wait after every attempt, starting at Base.
+type ExponentialBackoff struct {
+	Base time.Duration
+}
+
+func (b ExponentialBackoff) Delay(attempt int, previous time.Duration) time.Duration {
+	delay := b.Base << attempt
+	if attempt >= 63 || delay>>attempt != b.Base {
+		return math.MaxInt64
+	}
+	return delay
+}
+
+// DecorrelatedJitterBackoff waits a random time between Base and three
+// times the previous wait, so that contending callers spread out instead of
+// retrying in lockstep. Combine it with LockRetryConfig.MaxDelay.
+type DecorrelatedJitterBackoff struct {
+	Base time.Duration
+}
+
+func (b DecorrelatedJitterBackoff) Delay(attempt int, previous time.Duration) time.Duration {
+	upper := previous * 3
+	if upper <= b.Base {
+		return b.Base
+	}
+	return b.Base + rand.N(upper-b.Base)
+}
+
+// backoff returns the strategy of the config, exponential from
+// BackoffInterval by default.
+func (c LockRetryConfig) backoff() BackoffStrategy {
+	if c.Backoff != nil {
+		return c.Backoff
+	}
+	return ExponentialBackoff{Base: c.BackoffInterval}
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,112 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoffStrategies(t *testing.T) {
+	constant := ConstantBackoff{Interval: 50 * time.Millisecond}
+	if d := constant.Delay(3, time.Second); d != 50*time.Millisecond {
+		t.Errorf("expected constant delay, got %s", d)
+	}
+
+	exponential := ExponentialBackoff{Base: 10 * time.Millisecond}
+	for attempt, want := range []time.Duration{10, 20, 40, 80} {
+		if d := exponential.Delay(attempt, 0); d != want*time.Millisecond {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, want*time.Millisecond, d)
+		}
+	}
+	if d := exponential.Delay(100, 0); d <= 0 {
+		t.Errorf("expected overflowing delay to saturate, got %s", d)
+	}
+
+	jitter := DecorrelatedJitterBackoff{Base: 10 * time.Millisecond}
+	previous := time.Duration(0)
+	for attempt := 0; attempt < 20; attempt++ {
+		d := jitter.Delay(attempt, previous)
+		upper := max(previous*3, jitter.Base)
+		if d < jitter.Base || d > upper {
+			t.Fatalf("attempt %d: delay %s outside [%s, %s]", attempt, d, jitter.Base, upper)
+		}
+		previous = d
+	}
+}
+
+func newLockedFSM(t *testing.T, cfg LockRetryConfig) (*FSM, *FakeLockStorage) {
+	t.Helper()
+	storage := NewFakeStorage()
+	storage.SetState(context.Background(), "entity-backoff", "start")
+	lockStorage := &FakeLockStorage{StateStorage: storage, failCount: 100}
+
+	fsm, err := NewFSM([]State{
+		&TransitioningState{name: "start", nextStateName: "done"},
+		&TransitioningState{name: "done"},
+	}, WithAutoLock(lockStorage, cfg, nil), WithLogger(&MockLogger{}))
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+	return fsm, lockStorage
+}
+
+func TestFSM_Lock_ContextCancelled(t *testing.T) {
+	fsm, lockStorage := newLockedFSM(t, LockRetryConfig{
+		MaxRetries: 5,
+		Backoff:    ConstantBackoff{Interval: time.Minute},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := fsm.Trigger(ctx, "entity-backoff", NewBasicEvent("go", nil))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected waiting to stop with the context, took %s", elapsed)
+	}
+	if lockStorage.calledTimes != 1 {
+		t.Errorf("expected 1 lock attempt, got %d", lockStorage.calledTimes)
+	}
+}
+
+func TestFSM_Lock_MaxDelayAndFinalAttempt(t *testing.T) {
+	fsm, lockStorage := newLockedFSM(t, LockRetryConfig{
+		MaxRetries:      2,
+		BackoffInterval: time.Minute,
+		MaxDelay:        10 * time.Millisecond,
+	})
+
+	start := time.Now()
+	result, err := fsm.TriggerWithResult(context.Background(), "entity-backoff", NewBasicEvent("go", nil))
+	if !errors.Is(err, ErrLockNotAcquired) {
+		t.Fatalf("expected ErrLockNotAcquired, got %v", err)
+	}
+	if result.LockAttempts != 3 || lockStorage.calledTimes != 3 {
+		t.Errorf("expected 3 lock attempts, got %d", result.LockAttempts)
+	}
+	// Two capped waits, none after the final attempt.
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("unexpected time spent retrying: %s", elapsed)
+	}
+}
+
+func TestFSM_Lock_Deadline(t *testing.T) {
+	fsm, lockStorage := newLockedFSM(t, LockRetryConfig{
+		MaxRetries: 10,
+		Backoff:    ConstantBackoff{Interval: 30 * time.Millisecond},
+		Deadline:   50 * time.Millisecond,
+	})
+
+	err := fsm.Trigger(context.Background(), "entity-backoff", NewBasicEvent("go", nil))
+	if !errors.Is(err, ErrLockNotAcquired) {
+		t.Fatalf("expected ErrLockNotAcquired, got %v", err)
+	}
+	// A second wait would end past the deadline.
+	if lockStorage.calledTimes != 2 {
+		t.Errorf("expected the deadline to allow 2 attempts, got %d", lockStorage.calledTimes)
+	}
+}
@@ -0,0 +1,81 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+)
+
+type contextKey int
+
+const (
+	entityIDKey contextKey = iota
+	outputKey
+	runKey
+	actorKey
+	dataKey
+	entityKey
+)
+
+func withEntityID(ctx context.Context, entityID string) context.Context {
+	return context.WithValue(ctx, entityIDKey, entityID)
+}
+
+func entityIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(entityIDKey).(string)
+	return id
+}
+
+func withTransitionOutput(ctx context.Context, output any) context.Context {
+	return context.WithValue(ctx, outputKey, output)
+}
+
+// TransitionOutput returns the Transition.Output of the transition being
+// reported to a TransitionHook.
+func TransitionOutput(ctx context.Context) any {
+	return ctx.Value(outputKey)
+}
+
+// WithActor records who triggers the events handled with ctx, for the
+// TransitionRecords written to the HistoryStore.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey).(string)
+	return actor
+}
+
+func withRun(ctx context.Context, r *run) context.Context {
+	return context.WithValue(ctx, runKey, r)
+}
+
+// Raise queues an internal event for the entity whose event is being
+// handled. States, actions and guards call it with the context they receive;
+// the event is processed after the current transition, under the same lock,
+// before Trigger returns.
+func Raise(ctx context.Context, event Event) error {
+	r, ok := ctx.Value(runKey).(*run)
+	if !ok {
+		return errors.New("fsm: Raise called outside of Trigger")
+	}
+	r.queue = append(r.queue, event)
+	return nil
+}
+
+// replayedGuard reports, while replaying, whether the recorded transition
+// reached target, which stands in for the guard's result.
+func replayedGuard(ctx context.Context, target string) (passed, replaying bool) {
+	r, ok := ctx.Value(runKey).(*run)
+	if !ok || r.recorded == nil {
+		return false, false
+	}
+	return r.recorded[target], true
+}
+
+// recordGuard notes a guard evaluation when the run is a simulation.
+func recordGuard(ctx context.Context, result GuardResult) {
+	if r, ok := ctx.Value(runKey).(*run); ok && r.dryRun {
+		r.guards = append(r.guards, result)
+	}
+}
@@ -0,0 +1,116 @@
+package fsm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// extendedState is the data blob of the entity being handled. It is loaded
+// with the state and stored with the next one.
+type extendedState struct {
+	data  []byte
+	dirty bool
+}
+
+func withExtendedState(ctx context.Context, x *extendedState) context.Context {
+	return context.WithValue(ctx, dataKey, x)
+}
+
+func extendedStateFrom(ctx context.Context) (*extendedState, bool) {
+	x, ok := ctx.Value(dataKey).(*extendedState)
+	return x, ok
+}
+
+// LoadData decodes the extended state of the entity being handled, as JSON,
+// into a T. It returns the zero T for entities without data, and
+// ErrNoExtendedState when called outside of the FSM or when the StateStorage
+// is not a DataStorage.
+func LoadData[T any](ctx context.Context) (T, error) {
+	var v T
+	x, ok := extendedStateFrom(ctx)
+	if !ok {
+		return v, ErrNoExtendedState
+	}
+	if len(x.data) == 0 {
+		return v, nil
+	}
+	if err := json.Unmarshal(x.data, &v); err != nil {
+		return v, fmt.Errorf("fsm: decoding extended state: %w", err)
+	}
+	return v, nil
+}
+
+// SaveData replaces the extended state of the entity being handled. It is
+// stored together with the resulting state once the event has been handled,
+// even if the state does not change, and discarded if handling fails.
+func SaveData[T any](ctx context.Context, data T) error {
+	x, ok := extendedStateFrom(ctx)
+	if !ok {
+		return ErrNoExtendedState
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("fsm: encoding extended state: %w", err)
+	}
+	x.data = b
+	x.dirty = true
+	return nil
+}
+
+// getState reads the state of the entity and, with a DataStorage, its
+// extended state. The version is only read in optimistic mode. Failures
+// other than ErrEntityNotFound are wrapped in ErrStorageFailed.
+func (f *FSM) getState(ctx context.Context, entityID string) (string, *extendedState, int64, error) {
+	state, x, version, err := f.readState(ctx, entityID)
+	if err != nil && !errors.Is(err, ErrEntityNotFound) {
+		err = fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+	return state, x, version, err
+}
+
+func (f *FSM) readState(ctx context.Context, entityID string) (string, *extendedState, int64, error) {
+	if f.versioned != nil {
+		if vds, ok := f.versioned.(VersionedDataStorage); ok {
+			state, data, version, err := vds.GetStateDataVersion(ctx, entityID)
+			return state, &extendedState{data: data}, version, err
+		}
+		state, version, err := f.versioned.GetStateVersion(ctx, entityID)
+		return state, nil, version, err
+	}
+
+	ds, ok := f.storage.(DataStorage)
+	if !ok {
+		state, err := f.storage.GetState(ctx, entityID)
+		return state, nil, 0, err
+	}
+	state, data, err := ds.GetStateData(ctx, entityID)
+	return state, &extendedState{data: data}, 0, err
+}
+
+// setState stores the state of the entity together with its extended state,
+// if any. In optimistic mode, it fails with ErrVersionConflict unless the
+// entity is still at version, and returns the new version. Failures are
+// wrapped in ErrStorageFailed.
+func (f *FSM) setState(ctx context.Context, entityID, state string, x *extendedState, version int64) (int64, error) {
+	version, err := f.writeState(ctx, entityID, state, x, version)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+	return version, err
+}
+
+func (f *FSM) writeState(ctx context.Context, entityID, state string, x *extendedState, version int64) (int64, error) {
+	if f.versioned != nil {
+		if vds, ok := f.versioned.(VersionedDataStorage); ok && x != nil {
+			return vds.CompareAndSetStateData(ctx, entityID, state, x.data, version)
+		}
+		return f.versioned.CompareAndSetState(ctx, entityID, state, version)
+	}
+
+	if ds, ok := f.storage.(DataStorage); ok && x != nil {
+		return 0, ds.SetStateData(ctx, entityID, state, x.data)
+	}
+	return 0, f.storage.SetState(ctx, entityID, state)
+}
@@ -0,0 +1,146 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type FakeDataStorage struct {
+	*FakeStorage
+	data   map[string][]byte
+	writes int
+}
+
+func NewFakeDataStorage() *FakeDataStorage {
+	return &FakeDataStorage{FakeStorage: NewFakeStorage(), data: make(map[string][]byte)}
+}
+
+func (s *FakeDataStorage) GetStateData(ctx context.Context, entityID string) (string, []byte, error) {
+	state, err := s.GetState(ctx, entityID)
+	return state, s.data[entityID], err
+}
+
+func (s *FakeDataStorage) SetStateData(ctx context.Context, entityID, state string, data []byte) error {
+	s.writes++
+	s.data[entityID] = data
+	return s.SetState(ctx, entityID, state)
+}
+
+type scanData struct {
+	Targets  []string `json:"targets"`
+	Finished int      `json:"finished"`
+}
+
+func TestFSM_ExtendedState(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-data"
+	storage := NewFakeDataStorage()
+
+	addTargets := func(ctx context.Context, id string, event Event) (any, error) {
+		data, err := LoadData[scanData](ctx)
+		if err != nil {
+			return nil, err
+		}
+		data.Targets = append(data.Targets, event.Payload().([]string)...)
+		return nil, SaveData(ctx, data)
+	}
+	finishJob := func(ctx context.Context, id string, event Event) (any, error) {
+		data, err := LoadData[scanData](ctx)
+		if err != nil {
+			return nil, err
+		}
+		data.Finished++
+		if data.Finished == len(data.Targets) {
+			if err := Raise(ctx, NewBasicEvent("all_jobs_completed", nil)); err != nil {
+				return nil, err
+			}
+		}
+		return nil, SaveData(ctx, data)
+	}
+	hasTargets := func(ctx context.Context, id string, event Event) (bool, error) {
+		data, err := LoadData[scanData](ctx)
+		return len(data.Targets) > 0, err
+	}
+
+	def := NewDefinition().
+		From("pending").On("add_targets").Do(addTargets).To("pending").
+		From("pending").On("start_scan").Guard(hasTargets).To("running").
+		From("running").On("job_finished").Do(finishJob).To("running").
+		From("running").On("all_jobs_completed").To("completed").
+		Final("completed")
+
+	fsm, err := NewFSM(def.States(),
+		WithStateStorage(storage),
+		WithLogger(&MockLogger{}),
+		WithInitialState("pending"),
+		WithAutoInit(),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error creating FSM: %v", err)
+	}
+
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("start_scan", nil)); !errors.Is(err, ErrGuardRejected) {
+		t.Fatalf("expected ErrGuardRejected without targets, got %v", err)
+	}
+
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("add_targets", []string{"a", "b"})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := string(storage.data[entityID]); got != `{"targets":["a","b"],"finished":0}` {
+		t.Errorf("expected data to be saved without a state change, got %s", got)
+	}
+
+	if ok, err := fsm.CanTrigger(ctx, entityID, NewBasicEvent("start_scan", nil)); !ok || err != nil {
+		t.Errorf("expected guard to see the stored data, got %v, %v", ok, err)
+	}
+
+	for _, event := range []string{"start_scan", "job_finished", "job_finished"} {
+		if err := fsm.Trigger(ctx, entityID, NewBasicEvent(event, nil)); err != nil {
+			t.Fatalf("unexpected error on %s: %v", event, err)
+		}
+	}
+
+	state, data, _ := storage.GetStateData(ctx, entityID)
+	if state != "completed" || string(data) != `{"targets":["a","b"],"finished":2}` {
+		t.Errorf("unexpected final state data: %s %s", state, data)
+	}
+}
+
+func TestFSM_ExtendedStateDiscardedOnFailure(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-data-fail"
+	storage := NewFakeDataStorage()
+	storage.SetStateData(ctx, entityID, "pending", []byte(`{"finished":1}`))
+	writes := storage.writes
+
+	failing := func(ctx context.Context, id string, event Event) (any, error) {
+		if err := SaveData(ctx, scanData{Finished: 99}); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("boom")
+	}
+	def := NewDefinition().
+		From("pending").On("go").Do(failing).To("done")
+
+	fsm, err := NewFSM(def.States(), WithStateStorage(storage), WithLogger(&MockLogger{}))
+	if err != nil {
+		t.Fatalf("unexpected error creating FSM: %v", err)
+	}
+
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("go", nil)); err == nil {
+		t.Fatal("expected action error")
+	}
+	if storage.writes != writes || string(storage.data[entityID]) != `{"finished":1}` {
+		t.Errorf("expected data to be left untouched, got %s", storage.data[entityID])
+	}
+}
+
+func TestLoadData_WithoutDataStorage(t *testing.T) {
+	if _, err := LoadData[scanData](context.Background()); !errors.Is(err, ErrNoExtendedState) {
+		t.Errorf("expected ErrNoExtendedState, got %v", err)
+	}
+	if err := SaveData(context.Background(), scanData{}); !errors.Is(err, ErrNoExtendedState) {
+		t.Errorf("expected ErrNoExtendedState, got %v", err)
+	}
+}
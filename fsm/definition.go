@@ -0,0 +1,318 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TransitionRule is a single declared edge of the machine graph. GuardName
+// describes the guard in diagrams and is empty for unnamed guards.
+type TransitionRule struct {
+	From      string
+	Event     string
+	To        string
+	Guard     Guard
+	GuardName string
+	Action    Action
+}
+
+// Definition describes a machine as data: its states and the transitions
+// between them. Use States to obtain the states consumed by NewFSM.
+type Definition struct {
+	order  []string
+	states map[string]*declaredState
+	rules  []TransitionRule
+}
+
+func NewDefinition() *Definition {
+	return &Definition{
+		states: make(map[string]*declaredState),
+	}
+}
+
+// Add attaches state implementations to the definition. Declared transitions
+// take precedence; if the state also implements State, its HandleEvent is used
+// as a fallback for events with no declared transition.
+func (d *Definition) Add(states ...Lifecycle) *Definition {
+	for _, s := range states {
+		d.state(s.Name()).impl = s
+	}
+	return d
+}
+
+// Declare adds states that have no implementation of their own. Their
+// behaviour comes from the declared transitions only.
+func (d *Definition) Declare(states ...string) *Definition {
+	for _, name := range states {
+		d.state(name)
+	}
+	return d
+}
+
+// HasImplementation reports whether an implementation was attached to state
+// with Add.
+func (d *Definition) HasImplementation(state string) bool {
+	s, ok := d.states[state]
+	return ok && s.impl != nil
+}
+
+// Final marks states as terminal. Entities in a final state reject every
+// event with ErrEntityCompleted.
+func (d *Definition) Final(states ...string) *Definition {
+	for _, name := range states {
+		d.state(name).final = true
+	}
+	return d
+}
+
+// Composite nests children inside parent. The first child is the one entered
+// when a transition targets parent. Events a child does not handle bubble up
+// to its parent.
+func (d *Definition) Composite(parent string, children ...string) *Definition {
+	p := d.state(parent)
+	for _, name := range children {
+		c := d.state(name)
+		if c.parent == parent {
+			continue
+		}
+		c.parent = parent
+		p.children = append(p.children, name)
+	}
+	return d
+}
+
+// Parallel declares state as a parallel state whose regions are all active
+// at the same time. Every event is offered to each active region, and
+// DoneEvent(state) is raised once all regions reach a final state.
+func (d *Definition) Parallel(state string, regions ...string) *Definition {
+	d.Composite(state, regions...)
+	d.state(state).parallel = true
+	return d
+}
+
+func (d *Definition) IsParallel(state string) bool {
+	s, ok := d.states[state]
+	return ok && s.parallel
+}
+
+// History declares name as a history pseudo-state of the composite state
+// parent. A transition targeting name re-enters parent in the configuration
+// it had when it was last exited.
+func (d *Definition) History(name, parent string, kind HistoryKind) *Definition {
+	h := d.state(name)
+	h.parent = parent
+	h.history = kind
+	d.state(parent).hasHistory = true
+	return d
+}
+
+// HistoryType returns the kind of a history pseudo-state, or zero for regular
+// states.
+func (d *Definition) HistoryType(state string) HistoryKind {
+	if s, ok := d.states[state]; ok {
+		return s.history
+	}
+	return 0
+}
+
+// Timeout raises event when an entity stays in state for the given duration.
+// The delayed event is scheduled in the FSM's TimerStore when the state is
+// entered and cancelled when it is exited.
+func (d *Definition) Timeout(state string, after time.Duration, event string) *Definition {
+	d.state(state).timeout = &stateTimeout{after: after, event: event}
+	return d
+}
+
+// TimeoutOf returns the timeout declared for state, if any.
+func (d *Definition) TimeoutOf(state string) (time.Duration, string, bool) {
+	s, ok := d.states[state]
+	if !ok || s.timeout == nil {
+		return 0, "", false
+	}
+	return s.timeout.after, s.timeout.event, true
+}
+
+func (d *Definition) Parent(state string) string {
+	if s, ok := d.states[state]; ok {
+		return s.parent
+	}
+	return ""
+}
+
+func (d *Definition) Children(state string) []string {
+	if s, ok := d.states[state]; ok {
+		return append([]string(nil), s.children...)
+	}
+	return nil
+}
+
+func (d *Definition) IsFinal(state string) bool {
+	s, ok := d.states[state]
+	return ok && s.final
+}
+
+func (d *Definition) From(state string) *TransitionBuilder {
+	d.state(state)
+	return &TransitionBuilder{def: d, rule: TransitionRule{From: state}}
+}
+
+// States returns the declared states, in declaration order, ready to be
+// passed to NewFSM.
+func (d *Definition) States() []State {
+	states := make([]State, 0, len(d.order))
+	for _, name := range d.order {
+		states = append(states, d.states[name])
+	}
+	return states
+}
+
+// NewFSM creates a machine from the definition, starting in initial unless
+// it is empty. Options may still override the initial state.
+func (d *Definition) NewFSM(initial string, opts ...Option) (*FSM, error) {
+	if initial != "" {
+		opts = append([]Option{WithInitialState(initial)}, opts...)
+	}
+	return NewFSM(d.States(), opts...)
+}
+
+func (d *Definition) StateNames() []string {
+	return append([]string(nil), d.order...)
+}
+
+func (d *Definition) Rules() []TransitionRule {
+	return append([]TransitionRule(nil), d.rules...)
+}
+
+func (d *Definition) state(name string) *declaredState {
+	s, ok := d.states[name]
+	if !ok {
+		s = &declaredState{def: d, name: name}
+		d.states[name] = s
+		d.order = append(d.order, name)
+	}
+	return s
+}
+
+type TransitionBuilder struct {
+	def  *Definition
+	rule TransitionRule
+}
+
+func (b *TransitionBuilder) On(event string) *TransitionBuilder {
+	b.rule.Event = event
+	return b
+}
+
+// OnDone makes the transition fire when the source state completes, see
+// DoneEvent.
+func (b *TransitionBuilder) OnDone() *TransitionBuilder {
+	return b.On(DoneEvent(b.rule.From))
+}
+
+// Guard attaches a guard to the transition. Calling it more than once
+// requires all guards to pass.
+func (b *TransitionBuilder) Guard(g Guard) *TransitionBuilder {
+	if b.rule.Guard != nil {
+		g = And(b.rule.Guard, g)
+	}
+	b.rule.Guard = g
+	return b
+}
+
+// GuardNamed attaches a guard like Guard and records its name.
+func (b *TransitionBuilder) GuardNamed(name string, g Guard) *TransitionBuilder {
+	if b.rule.GuardName != "" {
+		name = b.rule.GuardName + " && " + name
+	}
+	b.rule.GuardName = name
+	return b.Guard(g)
+}
+
+// Do sets the action executed while the transition is taken.
+func (b *TransitionBuilder) Do(action Action) *TransitionBuilder {
+	b.rule.Action = action
+	return b
+}
+
+func (b *TransitionBuilder) To(state string) *Definition {
+	b.def.state(state)
+	b.rule.To = state
+	b.def.rules = append(b.def.rules, b.rule)
+	return b.def
+}
+
+type declaredState struct {
+	def      *Definition
+	name     string
+	impl     Lifecycle
+	final    bool
+	parent   string
+	children []string
+	parallel bool
+
+	history    HistoryKind
+	hasHistory bool
+
+	timeout *stateTimeout
+}
+
+type stateTimeout struct {
+	after time.Duration
+	event string
+}
+
+func (s *declaredState) Name() string {
+	return s.name
+}
+
+func (s *declaredState) OnEnter(ctx context.Context, event Event) error {
+	if s.impl == nil {
+		return nil
+	}
+	return s.impl.OnEnter(ctx, event)
+}
+
+func (s *declaredState) OnExit(ctx context.Context, event Event) error {
+	if s.impl == nil {
+		return nil
+	}
+	return s.impl.OnExit(ctx, event)
+}
+
+// HandleEvent picks the first declared transition for the event whose guard
+// passes. Rules are evaluated in declaration order.
+func (s *declaredState) HandleEvent(ctx context.Context, event Event) (Transition, error) {
+	rejected := false
+	for _, r := range s.def.rules {
+		if r.From != s.name || r.Event != event.Name() {
+			continue
+		}
+		if r.Guard == nil {
+			return Transition{NextState: r.To, Action: r.Action}, nil
+		}
+		ok, replaying := replayedGuard(ctx, r.To)
+		var err error
+		if !replaying {
+			ok, err = r.Guard(ctx, entityIDFromContext(ctx), event)
+		}
+		recordGuard(ctx, GuardResult{State: s.name, Event: event.Name(), Target: r.To, Guard: r.GuardName, Passed: ok, Err: err})
+		if err != nil {
+			return Transition{}, err
+		}
+		if ok {
+			return Transition{NextState: r.To, Action: r.Action}, nil
+		}
+		rejected = true
+	}
+
+	if rejected {
+		return Transition{}, guardRejected(event, s.name)
+	}
+
+	if handler, ok := s.impl.(State); ok {
+		return handler.HandleEvent(ctx, event)
+	}
+
+	return Transition{}, fmt.Errorf("%w for event '%s' in state '%s'", ErrNoTransition, event.Name(), s.name)
+}
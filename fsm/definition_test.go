@@ -0,0 +1,148 @@
+package fsm
+
+import (
+	"context"
+	"testing"
+)
+
+type LifecycleRecorder struct {
+	name  string
+	calls *[]string
+}
+
+func (s *LifecycleRecorder) Name() string {
+	return s.name
+}
+
+func (s *LifecycleRecorder) OnEnter(ctx context.Context, event Event) error {
+	*s.calls = append(*s.calls, "enter:"+s.name)
+	return nil
+}
+
+func (s *LifecycleRecorder) OnExit(ctx context.Context, event Event) error {
+	*s.calls = append(*s.calls, "exit:"+s.name)
+	return nil
+}
+
+func TestDefinition_Trigger(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-def"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "pending")
+
+	var calls []string
+	def := NewDefinition().
+		Add(
+			&LifecycleRecorder{name: "pending", calls: &calls},
+			&LifecycleRecorder{name: "running", calls: &calls},
+		).
+		From("pending").On("start_scan").To("running").
+		From("running").On("all_jobs_completed").To("completed")
+
+	fsm, err := NewFSM(def.States(), WithStateStorage(storage), WithLogger(&MockLogger{}))
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("start_scan", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("all_jobs_completed", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("start_scan", nil)); err == nil {
+		t.Error("expected error for undeclared transition")
+	}
+
+	state, _ := storage.GetState(ctx, entityID)
+	if state != "completed" {
+		t.Errorf("expected state %q, got %q", "completed", state)
+	}
+
+	expected := []string{"exit:pending", "enter:running", "exit:running"}
+	if len(calls) != len(expected) {
+		t.Fatalf("expected calls %v, got %v", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("expected calls %v, got %v", expected, calls)
+			break
+		}
+	}
+}
+
+func TestDefinition_HandleEventFallback(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-fallback"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "start")
+
+	def := NewDefinition().
+		Add(&TransitioningState{name: "start", nextStateName: "fallback"}).
+		From("start").On("declared").To("done").
+		From("fallback").On("declared").To("done")
+
+	fsm, err := NewFSM(def.States(), WithStateStorage(storage), WithLogger(&MockLogger{}))
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("other", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	state, _ := storage.GetState(ctx, entityID)
+	if state != "fallback" {
+		t.Errorf("expected state %q, got %q", "fallback", state)
+	}
+}
+
+func TestDefinition_Introspection(t *testing.T) {
+	def := NewDefinition().
+		From("a").On("go").To("b").
+		From("b").On("back").To("a")
+
+	names := def.StateNames()
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("unexpected state names: %v", names)
+	}
+
+	rules := def.Rules()
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(rules))
+	}
+	if r := rules[1]; r.From != "b" || r.Event != "back" || r.To != "a" {
+		t.Errorf("unexpected rule: %+v", rules[1])
+	}
+}
+
+func TestDefinition_Composite(t *testing.T) {
+	def := NewDefinition().Composite("active", "running", "paused")
+
+	if parent := def.Parent("paused"); parent != "active" {
+		t.Errorf("expected parent %q, got %q", "active", parent)
+	}
+	children := def.Children("active")
+	if len(children) != 2 || children[0] != "running" || children[1] != "paused" {
+		t.Errorf("unexpected children: %v", children)
+	}
+}
+
+func TestDefinition_NewFSM(t *testing.T) {
+	ctx := context.Background()
+	storage := NewFakeStorage()
+	def := NewDefinition().
+		From("pending").On("start").To("running").
+		Final("running")
+
+	fsm, err := def.NewFSM("pending", WithStateStorage(storage), WithLogger(&MockLogger{}))
+	if err != nil {
+		t.Fatalf("unexpected error creating FSM: %v", err)
+	}
+	if err := fsm.Init(ctx, "entity-def"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state, _ := storage.GetState(ctx, "entity-def"); state != "pending" {
+		t.Errorf("expected initial state pending, got %s", state)
+	}
+}
@@ -0,0 +1,314 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// defaultMaxChainDepth bounds the internal events processed by a single
+// Trigger call, see WithMaxChainDepth.
+const defaultMaxChainDepth = 100
+
+// DoneEvent is the name of the event raised when a composite state reaches
+// one of its final children, or when every region of a parallel state has.
+func DoneEvent(state string) string {
+	return "done.state." + state
+}
+
+// configuration is the set of active leaf states of an entity, in document
+// order. Outside parallel states it holds a single leaf.
+type configuration []string
+
+// parseConfiguration reads a persisted value. A declared state name is taken
+// whole, so that names containing RegionSeparator keep working in machines
+// without parallel states.
+func (f *FSM) parseConfiguration(value string) configuration {
+	if _, ok := f.states[value]; ok {
+		return configuration{value}
+	}
+	paths := strings.Split(value, RegionSeparator)
+	config := make(configuration, 0, len(paths))
+	for _, path := range paths {
+		config = append(config, f.leafOf(path))
+	}
+	return config
+}
+
+func (f *FSM) formatConfiguration(config configuration) string {
+	paths := make([]string, len(config))
+	for i, leaf := range config {
+		paths[i] = f.pathOf(leaf)
+	}
+	return strings.Join(paths, RegionSeparator)
+}
+
+// activeStates returns every active state, leaves and their ancestors.
+func (f *FSM) activeStates(config configuration) map[string]bool {
+	active := make(map[string]bool)
+	for _, leaf := range config {
+		for _, s := range f.lineage(leaf) {
+			active[s] = true
+		}
+	}
+	return active
+}
+
+// isCompleted reports whether the entity is done in this configuration. Final
+// states nested in a composite state only complete their parent.
+func (f *FSM) isCompleted(config configuration) bool {
+	return len(config) == 1 && f.isFinal(config[0]) && f.parentOf(config[0]) == ""
+}
+
+// run holds what the microsteps of a single Trigger call share. A dry run,
+// see Simulate, skips hooks and actions and records guard results.
+type run struct {
+	entityID string
+	history  map[string]string
+	queue    []Event
+
+	dryRun bool
+	guards []GuardResult
+
+	// recorded holds, while replaying a TransitionRecord, the states active
+	// after it. They decide guards instead of re-running them, see replay.
+	recorded map[string]bool
+}
+
+func newRun(entityID string) *run {
+	return &run{entityID: entityID, history: make(map[string]string)}
+}
+
+// step is one microstep: the transitions taken for a single event.
+type step struct {
+	event   Event
+	from    string
+	to      string
+	output  any
+	changed bool
+	exited  []string
+	entered []string
+}
+
+type plannedTransition struct {
+	transition Transition
+	source     string
+	internal   bool
+	exits      []string
+	entries    []string
+}
+
+// microstep offers the event to every active region and takes the enabled
+// transitions. When regions select conflicting transitions, the one found
+// first in document order wins.
+func (f *FSM) microstep(ctx context.Context, r *run, config configuration, event Event) (configuration, step, error) {
+	entityID := r.entityID
+	st := step{event: event, from: f.formatConfiguration(config)}
+	st.to = st.from
+
+	fail := func(to string, err error) error {
+		return &TransitionError{EntityID: entityID, From: st.from, To: to, Event: event.Name(), Err: err}
+	}
+
+	var plans []plannedTransition
+	var rejected, unhandled error
+	exiting := make(map[string]bool)
+	sources := make(map[string]bool)
+
+	for _, leaf := range config {
+		transition, source, err := f.handle(ctx, entityID, leaf, event)
+		switch {
+		case errors.Is(err, ErrGuardRejected):
+			if rejected == nil {
+				rejected = err
+			}
+			continue
+		case errors.Is(err, ErrNoTransition):
+			if unhandled == nil {
+				unhandled = err
+			}
+			continue
+		case err != nil:
+			return config, st, fail("", err)
+		}
+		if sources[source] {
+			continue
+		}
+		sources[source] = true
+
+		p := plannedTransition{
+			transition: transition,
+			source:     source,
+			internal:   transition.NextState == "" || (source == leaf && transition.NextState == leaf),
+		}
+		if !p.internal {
+			if _, ok := f.states[transition.NextState]; !ok {
+				return config, st, fail(transition.NextState, fmt.Errorf("%w: next state '%s'", ErrUnknownState, transition.NextState))
+			}
+			domain := f.domain(source, transition.NextState)
+			p.exits = f.exitSet(config, domain)
+			if conflicts(exiting, p.exits) {
+				continue
+			}
+			for _, s := range p.exits {
+				exiting[s] = true
+			}
+
+			targets := []string{transition.NextState}
+			if f.historyKind(transition.NextState) != 0 {
+				if targets, err = f.restore(ctx, r, transition.NextState); err != nil {
+					return config, st, fail(transition.NextState, err)
+				}
+			}
+			p.entries = f.entrySet(domain, targets)
+			f.remember(r, config, p.exits)
+		}
+		plans = append(plans, p)
+	}
+
+	if len(plans) == 0 {
+		if rejected != nil {
+			return config, st, fail("", rejected)
+		}
+		return config, st, fail("", unhandled)
+	}
+
+	next := config
+	for _, p := range plans {
+		if !p.internal {
+			next = f.apply(next, p)
+		}
+	}
+	to := f.formatConfiguration(next)
+
+	var entered []string
+	for _, p := range plans {
+		st.exited = append(st.exited, p.exits...)
+		entered = append(entered, p.entries...)
+		if r.dryRun {
+			continue
+		}
+
+		for _, name := range p.exits {
+			if err := f.states[name].OnExit(ctx, event); err != nil {
+				return config, st, fail(to, fmt.Errorf("%w: OnExit of '%s': %w", ErrHookFailed, name, err))
+			}
+		}
+
+		if p.transition.Action != nil {
+			output, err := p.transition.Action(ctx, entityID, event)
+			if err != nil {
+				f.logger.Errorf("FSM [%s]: transition action failed: %v", entityID, err)
+				return config, st, fail(to, err)
+			}
+			if output != nil {
+				p.transition.Output = output
+			}
+		}
+		if p.transition.Output != nil {
+			st.output = p.transition.Output
+		}
+
+		for _, name := range p.entries {
+			if err := f.states[name].OnEnter(ctx, event); err != nil {
+				return config, st, fail(to, fmt.Errorf("%w: OnEnter of '%s': %w", ErrHookFailed, name, err))
+			}
+		}
+	}
+
+	// An external self-transition leaves the configuration as it was but
+	// still exits and re-enters states, so it counts as a change.
+	st.to = to
+	st.changed = len(st.exited) > 0 || len(entered) > 0
+	st.entered = entered
+	r.queue = append(r.queue, f.doneEvents(entered, next)...)
+
+	return next, st, nil
+}
+
+// drain processes the internal events queued in the run, each in its own
+// microstep. Internal events no state handles are dropped. from and cause
+// describe the configuration and event that started the chain.
+func (f *FSM) drain(ctx context.Context, r *run, config configuration, from string, cause Event) (configuration, []step, error) {
+	var steps []step
+	for depth := 1; len(r.queue) > 0; depth++ {
+		if depth > f.maxChainDepth {
+			f.logger.Errorf("FSM [%s]: more than %d internal events", r.entityID, f.maxChainDepth)
+			return config, steps, &TransitionError{EntityID: r.entityID, From: from, Event: cause.Name(), Err: ErrChainDepthExceeded}
+		}
+
+		internal := r.queue[0]
+		r.queue = r.queue[1:]
+
+		next, st, err := f.microstep(ctx, r, config, internal)
+		if errors.Is(err, ErrNoTransition) || errors.Is(err, ErrGuardRejected) {
+			f.logger.Infof("FSM [%s]: internal event '%s' not handled: %v", r.entityID, internal.Name(), err)
+			continue
+		}
+		if err != nil {
+			f.logger.Errorf("FSM [%s]: error handling internal event: %v", r.entityID, err)
+			return config, steps, err
+		}
+
+		config = next
+		steps = append(steps, st)
+	}
+	return config, steps, nil
+}
+
+// apply returns the configuration after the planned transition is taken.
+func (f *FSM) apply(config configuration, p plannedTransition) configuration {
+	exited := make(map[string]bool, len(p.exits))
+	for _, s := range p.exits {
+		exited[s] = true
+	}
+
+	next := make(configuration, 0, len(config))
+	for _, leaf := range config {
+		if !exited[leaf] {
+			next = append(next, leaf)
+		}
+	}
+	for _, s := range p.entries {
+		if len(f.childrenOf(s)) == 0 {
+			next = append(next, s)
+		}
+	}
+	f.sortStates(next)
+	return next
+}
+
+// doneEvents lists the completion events caused by entering final states.
+func (f *FSM) doneEvents(entered []string, config configuration) []Event {
+	active := f.activeStates(config)
+	raised := make(map[string]bool)
+	var events []Event
+	raise := func(state string) {
+		if !raised[state] {
+			raised[state] = true
+			events = append(events, NewBasicEvent(DoneEvent(state), nil))
+		}
+	}
+
+	for _, s := range entered {
+		parent := f.parentOf(s)
+		if !f.isFinal(s) || parent == "" {
+			continue
+		}
+		raise(parent)
+		if grandparent := f.parentOf(parent); f.isParallel(grandparent) && f.isDone(grandparent, active) {
+			raise(grandparent)
+		}
+	}
+	return events
+}
+
+func conflicts(exiting map[string]bool, exits []string) bool {
+	for _, s := range exits {
+		if exiting[s] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+)
+
+// EntityLoader returns the domain object of an entity, e.g. from a database.
+// It runs under the entity lock, after the state has been read, so it may
+// use LoadData to build the object from the extended state.
+type EntityLoader func(ctx context.Context, entityID string) (any, error)
+
+// WithEntity passes the domain object of the entity to the states, guards
+// and actions run by Trigger, see Entity. It takes precedence over the
+// FSM's EntityLoader.
+func WithEntity(ctx context.Context, entity any) context.Context {
+	return context.WithValue(ctx, entityKey, entity)
+}
+
+// Entity returns the domain object of the entity being handled, as passed
+// with WithEntity or returned by the EntityLoader. States can then be shared
+// by every entity instead of holding a pointer to one.
+func Entity[T any](ctx context.Context) (T, bool) {
+	entity, ok := ctx.Value(entityKey).(T)
+	return entity, ok
+}
+
+// EntityID returns the ID of the entity being handled.
+func EntityID(ctx context.Context) string {
+	return entityIDFromContext(ctx)
+}
+
+// loadEntity attaches the entity returned by the EntityLoader, unless one is
+// already in ctx.
+func (f *FSM) loadEntity(ctx context.Context, entityID string) (context.Context, error) {
+	if f.entityLoader == nil || ctx.Value(entityKey) != nil {
+		return ctx, nil
+	}
+	entity, err := f.entityLoader(ctx, entityID)
+	if err != nil {
+		return ctx, fmt.Errorf("fsm: loading entity '%s': %w", entityID, err)
+	}
+	return WithEntity(ctx, entity), nil
+}
@@ -0,0 +1,127 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type order struct {
+	ID     string
+	Status string
+	Paid   bool
+}
+
+// statusState is shared by every order and updates the one in the context.
+type statusState struct {
+	name string
+}
+
+func (s *statusState) Name() string { return s.name }
+
+func (s *statusState) OnEnter(ctx context.Context, event Event) error {
+	o, ok := Entity[*order](ctx)
+	if !ok {
+		return errors.New("no order in context")
+	}
+	if o.ID != EntityID(ctx) {
+		return errors.New("order does not match the entity")
+	}
+	o.Status = s.name
+	return nil
+}
+
+func (s *statusState) OnExit(ctx context.Context, event Event) error { return nil }
+
+func newOrderFSM(t *testing.T, storage StateStorage, opts ...Option) *FSM {
+	t.Helper()
+	isPaid := func(ctx context.Context, entityID string, event Event) (bool, error) {
+		o, _ := Entity[*order](ctx)
+		return o != nil && o.Paid, nil
+	}
+	def := NewDefinition().
+		Add(&statusState{"placed"}, &statusState{"shipped"}).
+		From("placed").On("ship").Guard(isPaid).To("shipped")
+
+	opts = append([]Option{
+		WithStateStorage(storage),
+		WithLogger(&MockLogger{}),
+		WithInitialState("placed"),
+	}, opts...)
+	fsm, err := NewFSM(def.States(), opts...)
+	if err != nil {
+		t.Fatalf("unexpected error creating FSM: %v", err)
+	}
+	return fsm
+}
+
+func TestFSM_WithEntity(t *testing.T) {
+	ctx := context.Background()
+	fsm := newOrderFSM(t, NewFakeStorage())
+
+	paid := &order{ID: "order-1", Paid: true}
+	unpaid := &order{ID: "order-2"}
+
+	for _, o := range []*order{paid, unpaid} {
+		if err := fsm.Init(WithEntity(ctx, o), o.ID); err != nil {
+			t.Fatalf("unexpected error initializing %s: %v", o.ID, err)
+		}
+		if o.Status != "placed" {
+			t.Errorf("expected %s to be placed, got %q", o.ID, o.Status)
+		}
+	}
+
+	if err := fsm.Trigger(WithEntity(ctx, paid), paid.ID, NewBasicEvent("ship", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fsm.Trigger(WithEntity(ctx, unpaid), unpaid.ID, NewBasicEvent("ship", nil)); !errors.Is(err, ErrGuardRejected) {
+		t.Errorf("expected ErrGuardRejected, got %v", err)
+	}
+
+	if paid.Status != "shipped" || unpaid.Status != "placed" {
+		t.Errorf("unexpected statuses: %q, %q", paid.Status, unpaid.Status)
+	}
+}
+
+func TestFSM_EntityLoader(t *testing.T) {
+	ctx := context.Background()
+	orders := map[string]*order{
+		"order-1": {ID: "order-1", Paid: true},
+	}
+	loads := 0
+	loader := func(ctx context.Context, entityID string) (any, error) {
+		loads++
+		o, ok := orders[entityID]
+		if !ok {
+			return nil, errors.New("order not found")
+		}
+		return o, nil
+	}
+	fsm := newOrderFSM(t, NewFakeStorage(), WithEntityLoader(loader), WithAutoInit())
+
+	if ok, err := fsm.CanTrigger(ctx, "order-1", NewBasicEvent("ship", nil)); !ok || err != nil {
+		t.Errorf("expected the guard to see the loaded order, got %v, %v", ok, err)
+	}
+
+	if err := fsm.Trigger(ctx, "order-1", NewBasicEvent("ship", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if orders["order-1"].Status != "shipped" {
+		t.Errorf("expected loaded order to be shipped, got %q", orders["order-1"].Status)
+	}
+	if loads != 2 {
+		t.Errorf("expected the order to be loaded once per call, got %d loads", loads)
+	}
+
+	passed := &order{ID: "order-2", Paid: true}
+	if err := fsm.Trigger(WithEntity(ctx, passed), "order-2", NewBasicEvent("ship", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loads != 2 || passed.Status != "shipped" {
+		t.Errorf("expected the passed order to be used, got %d loads and status %q", loads, passed.Status)
+	}
+
+	if err := fsm.Trigger(ctx, "order-3", NewBasicEvent("ship", nil)); err == nil {
+		t.Error("expected loader error")
+	}
+}
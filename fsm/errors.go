@@ -0,0 +1,44 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrEntityNotFound  = errors.New("fsm: entity not found")
+	ErrEntityExists    = errors.New("fsm: entity already exists")
+	ErrUnknownState    = errors.New("fsm: unknown state")
+	ErrNoTransition    = errors.New("fsm: no transition")
+	ErrEntityCompleted = errors.New("fsm: entity is in a final state")
+	ErrLockNotAcquired = errors.New("fsm: unable to acquire lock")
+	ErrHookFailed      = errors.New("fsm: state hook failed")
+	ErrGuardRejected   = errors.New("fsm: transition rejected by guard")
+
+	ErrChainDepthExceeded = errors.New("fsm: too many internal events")
+	ErrInvalidDefinition  = errors.New("fsm: invalid definition")
+	ErrNoExtendedState    = errors.New("fsm: extended state not available")
+	ErrVersionConflict    = errors.New("fsm: state changed concurrently")
+	ErrStorageFailed      = errors.New("fsm: storage failed")
+)
+
+// TransitionError reports a failure while handling an event for an entity.
+// To is empty when the failure happened before a target state was chosen.
+type TransitionError struct {
+	EntityID string
+	From     string
+	To       string
+	Event    string
+	Err      error
+}
+
+func (e *TransitionError) Error() string {
+	if e.To == "" {
+		return fmt.Sprintf("fsm: entity '%s' event '%s' in state '%s': %v", e.EntityID, e.Event, e.From, e.Err)
+	}
+	return fmt.Sprintf("fsm: entity '%s' event '%s' from '%s' to '%s': %v", e.EntityID, e.Event, e.From, e.To, e.Err)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,104 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFSM_Trigger_Errors(t *testing.T) {
+	cases := []struct {
+		name         string
+		initialState string
+		states       []State
+		expectErr    error
+		expectTo     string
+	}{
+		{
+			name:      "entity not found",
+			states:    []State{&TransitioningState{name: "start"}},
+			expectErr: ErrEntityNotFound,
+		},
+		{
+			name:         "unknown current state",
+			initialState: "ghost",
+			states:       []State{&TransitioningState{name: "start"}},
+			expectErr:    ErrUnknownState,
+		},
+		{
+			name:         "unknown next state",
+			initialState: "start",
+			states:       []State{&TransitioningState{name: "start", nextStateName: "ghost"}},
+			expectErr:    ErrUnknownState,
+			expectTo:     "ghost",
+		},
+		{
+			name:         "no transition",
+			initialState: "start",
+			states:       NewDefinition().From("start").On("other").To("done").States(),
+			expectErr:    ErrNoTransition,
+		},
+		{
+			name:         "hook failed",
+			initialState: "start",
+			states: []State{
+				&TransitioningState{name: "start", nextStateName: "done", failOnExit: true},
+				&TransitioningState{name: "done"},
+			},
+			expectErr: ErrHookFailed,
+			expectTo:  "done",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			entityID := "entity-errors"
+			storage := NewFakeStorage()
+			if tt.initialState != "" {
+				storage.SetState(ctx, entityID, tt.initialState)
+			}
+
+			fsm, err := NewFSM(tt.states, WithStateStorage(storage), WithLogger(&MockLogger{}))
+			if err != nil {
+				t.Fatalf("failed to create FSM: %v", err)
+			}
+
+			err = fsm.Trigger(ctx, entityID, NewBasicEvent("go", nil))
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+			}
+
+			if tt.initialState == "" {
+				return
+			}
+
+			var terr *TransitionError
+			if !errors.As(err, &terr) {
+				t.Fatalf("expected a *TransitionError, got %T", err)
+			}
+			if terr.EntityID != entityID || terr.From != tt.initialState || terr.To != tt.expectTo || terr.Event != "go" {
+				t.Errorf("unexpected transition error: %+v", terr)
+			}
+		})
+	}
+}
+
+func TestFSM_Trigger_LockNotAcquired(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-lock-error"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "start")
+
+	fsm, err := NewFSM([]State{&TransitioningState{name: "start"}},
+		WithLogger(&MockLogger{}),
+		WithAutoLock(&FakeLockStorage{StateStorage: storage, failCount: 1}, LockRetryConfig{}, nil),
+	)
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("go", nil)); !errors.Is(err, ErrLockNotAcquired) {
+		t.Errorf("expected ErrLockNotAcquired, got %v", err)
+	}
+}
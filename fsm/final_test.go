@@ -0,0 +1,65 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type FakeCompletableStorage struct {
+	*FakeStorage
+	completed map[string]string
+}
+
+func (s *FakeCompletableStorage) Complete(ctx context.Context, entityID, state string) error {
+	s.completed[entityID] = state
+	return nil
+}
+
+func TestFSM_FinalState(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-final"
+	storage := &FakeCompletableStorage{FakeStorage: NewFakeStorage(), completed: make(map[string]string)}
+	storage.SetState(ctx, entityID, "running")
+
+	var completedState, completedEvent string
+	hook := func(ctx context.Context, id, state string, event Event) {
+		completedState = state
+		completedEvent = event.Name()
+	}
+
+	def := NewDefinition().
+		From("running").On("finish").To("completed").
+		From("completed").On("restart").To("running").
+		Final("completed")
+
+	fsm, err := NewFSM(def.States(),
+		WithStateStorage(storage),
+		WithLogger(&MockLogger{}),
+		WithCompletionHook(hook),
+	)
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("finish", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if completedState != "completed" || completedEvent != "finish" {
+		t.Errorf("unexpected completion hook values: state=%q event=%q", completedState, completedEvent)
+	}
+	if storage.completed[entityID] != "completed" {
+		t.Errorf("expected storage to complete entity, got %v", storage.completed)
+	}
+
+	err = fsm.Trigger(ctx, entityID, NewBasicEvent("restart", nil))
+	if !errors.Is(err, ErrEntityCompleted) {
+		t.Fatalf("expected ErrEntityCompleted, got %v", err)
+	}
+
+	state, _ := storage.GetState(ctx, entityID)
+	if state != "completed" {
+		t.Errorf("expected state %q, got %q", "completed", state)
+	}
+}
@@ -3,129 +3,502 @@ package fsm
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 )
 
+// InitEvent is the name of the event passed to the initial state's OnEnter
+// when an entity is initialized.
+const InitEvent = "fsm.init"
+
 type TransitionHook func(ctx context.Context, entityID, from, to string, event Event)
 
 type LockFailureHandler func(ctx context.Context, entityID string, event Event)
 
+// CompletionHook is called when an entity reaches a final state.
+type CompletionHook func(ctx context.Context, entityID, state string, event Event)
+
 type FSM struct {
 	states         map[string]State
 	storage        StateStorage
 	logger         Logger
 	transitionHook TransitionHook
+	completionHook CompletionHook
 
 	lockableStorage    LockableStorage
 	lockRetry          LockRetryConfig
 	lockFailureHandler LockFailureHandler
+
+	versioned  VersionedStorage
+	casRetries int
+
+	initialState string
+	autoInit     bool
+	entityLoader EntityLoader
+
+	timers        TimerStore
+	history       HistoryStore
+	maxChainDepth int
+
+	strict   bool
+	problems []ValidationProblem
+
+	docOrder map[string]int
 }
 
 func NewFSM(states []State, opts ...Option) (*FSM, error) {
 	stateMap := make(map[string]State)
-	for _, s := range states {
+	var problems []ValidationProblem
+	for i, s := range states {
+		if s == nil {
+			problems = append(problems, ValidationProblem{
+				Kind:    NilState,
+				Message: fmt.Sprintf("state at index %d is nil", i),
+			})
+			continue
+		}
+		if _, ok := stateMap[s.Name()]; ok {
+			problems = append(problems, ValidationProblem{
+				Kind:    DuplicateState,
+				State:   s.Name(),
+				Message: fmt.Sprintf("state '%s' is declared more than once", s.Name()),
+			})
+		}
 		stateMap[s.Name()] = s
 	}
 
 	f := &FSM{
-		states:  stateMap,
-		logger:  &DefaultLogger{},
-		storage: nil,
+		states:        stateMap,
+		logger:        &DefaultLogger{},
+		storage:       nil,
+		maxChainDepth: defaultMaxChainDepth,
+		problems:      problems,
 	}
 
 	for _, opt := range opts {
 		opt(f)
 	}
 
+	f.indexStates(states)
+
 	if f.storage == nil {
 		return nil, errors.New("fsm: StateStorage is required")
 	}
 
+	if problems := f.nameProblems(f.stateNames()); len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	if f.initialState != "" {
+		if _, ok := f.states[f.initialState]; !ok {
+			return nil, fmt.Errorf("%w: initial state '%s'", ErrUnknownState, f.initialState)
+		}
+	} else if f.autoInit {
+		return nil, errors.New("fsm: auto-init requires an initial state")
+	}
+
+	if err := f.Validate(); err != nil {
+		if f.strict {
+			return nil, err
+		}
+		f.logger.Infof("FSM: %v", err)
+	}
+
 	return f, nil
 }
 
+// TriggerResult describes the outcome of a Trigger call. Conflicts counts
+// the attempts retried after a version conflict in optimistic mode.
+type TriggerResult struct {
+	From         string
+	To           string
+	Changed      bool
+	Output       any
+	Duration     time.Duration
+	LockAttempts int
+	Conflicts    int
+}
+
 func (f *FSM) Trigger(ctx context.Context, entityID string, event Event) error {
-	var unlock func()
-	var err error
+	_, err := f.TriggerWithResult(ctx, entityID, event)
+	return err
+}
 
-	if f.lockableStorage != nil {
-		// Retry loop
-		for attempt := 0; attempt <= f.lockRetry.MaxRetries; attempt++ {
-			unlock, err = f.lockableStorage.Lock(ctx, entityID)
-			if err == nil {
-				if attempt > 0 {
-					f.logger.Infof("FSM [%s]: lock acquired after %d attempt(s)", entityID, attempt+1)
-				}
-				break
-			}
+// TriggerWithResult behaves like Trigger and also reports what happened. The
+// result is filled in as far as the trigger got, even when an error is
+// returned.
+func (f *FSM) TriggerWithResult(ctx context.Context, entityID string, event Event) (TriggerResult, error) {
+	return f.trigger(ctx, entityID, event, "")
+}
 
-			if f.lockRetry.MaxRetries == 0 {
-				f.logger.Infof("FSM [%s]: lock failed. No retries set.", entityID)
-				break
-			}
+// trigger handles the event for the entity. When requiredState is set, the
+// event is dropped unless that state is still active once the lock is held.
+func (f *FSM) trigger(ctx context.Context, entityID string, event Event, requiredState string) (result TriggerResult, err error) {
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
 
-			delay := f.lockRetry.BackoffInterval * (1 << attempt)
-			f.logger.Infof("FSM [%s]: lock attempt %d failed, retrying in %s", entityID, attempt+1, delay)
-			time.Sleep(delay)
+	if f.lockableStorage != nil {
+		var unlock func()
+		unlock, result.LockAttempts, err = f.lock(ctx, entityID, event)
+		if err != nil {
+			return result, err
 		}
+		defer unlock()
+	}
 
-		if err != nil {
-			f.logger.Errorf("FSM [%s]: failed to acquire lock after retries: %v", entityID, err)
-			if f.lockFailureHandler != nil {
-				f.lockFailureHandler(ctx, entityID, event)
-			}
-			return err
+	for {
+		err = f.handleEvent(ctx, entityID, event, requiredState, &result)
+		if !errors.Is(err, ErrVersionConflict) || result.Conflicts >= f.casRetries {
+			return result, err
 		}
+		result.Conflicts++
+		f.logger.Infof("FSM [%s]: %v, retrying", entityID, err)
+	}
+}
 
-		defer unlock()
+// handleEvent reads the entity, runs the transitions for the event and
+// stores the result. In optimistic mode it fails with ErrVersionConflict when
+// the entity changed in the meantime.
+func (f *FSM) handleEvent(ctx context.Context, entityID string, event Event, requiredState string, result *TriggerResult) error {
+	current, x, version, err := f.getState(ctx, entityID)
+	if x != nil {
+		ctx = withExtendedState(ctx, x)
+	}
+	initialize := errors.Is(err, ErrEntityNotFound) && f.autoInit
+	if err != nil && !initialize {
+		return err
 	}
 
-	currentStateName, err := f.storage.GetState(ctx, entityID)
-	if err != nil {
+	ctx = withEntityID(ctx, entityID)
+	if ctx, err = f.loadEntity(ctx, entityID); err != nil {
 		return err
 	}
 
-	currentState, ok := f.states[currentStateName]
-	if !ok {
-		return errors.New("fsm: current state not found: " + currentStateName)
+	if initialize {
+		if current, version, err = f.initialize(ctx, entityID); err != nil {
+			return err
+		}
+	}
+	result.From = current
+	result.To = current
+
+	config := f.parseConfiguration(current)
+	for _, leaf := range config {
+		if _, ok := f.states[leaf]; !ok {
+			return &TransitionError{
+				EntityID: entityID,
+				From:     current,
+				Event:    event.Name(),
+				Err:      fmt.Errorf("%w: current state '%s'", ErrUnknownState, current),
+			}
+		}
+	}
+
+	if requiredState != "" && !f.activeStates(config)[requiredState] {
+		f.logger.Infof("FSM [%s]: dropping event '%s', state '%s' is no longer active", entityID, event.Name(), requiredState)
+		return nil
+	}
+
+	if f.isCompleted(config) {
+		f.logger.Infof("FSM [%s]: ignoring event '%s' in final state '%s'", entityID, event.Name(), current)
+		return &TransitionError{EntityID: entityID, From: current, Event: event.Name(), Err: ErrEntityCompleted}
 	}
 
-	f.logger.Infof("FSM [%s]: handling event '%s' in state '%s'", entityID, event.Name(), currentStateName)
+	f.logger.Infof("FSM [%s]: handling event '%s' in state '%s'", entityID, event.Name(), current)
+
+	r := newRun(entityID)
+	ctx = withRun(ctx, r)
 
-	transition, err := currentState.HandleEvent(ctx, event)
+	config, st, err := f.microstep(ctx, r, config, event)
+	if errors.Is(err, ErrGuardRejected) {
+		f.logger.Infof("FSM [%s]: %v", entityID, err)
+		return err
+	}
 	if err != nil {
 		f.logger.Errorf("FSM [%s]: error handling event: %v", entityID, err)
 		return err
 	}
+	result.Output = st.output
+
+	config, internal, err := f.drain(ctx, r, config, current, event)
+	if err != nil {
+		return err
+	}
+	steps := append([]step{st}, internal...)
 
-	if transition.NextState == "" || transition.NextState == currentStateName {
+	next := f.formatConfiguration(config)
+	changed := false
+	for _, st := range steps {
+		changed = changed || st.changed
+	}
+	dirty := x != nil && x.dirty
+	if !changed && !dirty {
 		f.logger.Infof("FSM [%s]: no state change", entityID)
 		return nil
 	}
 
-	nextState, ok := f.states[transition.NextState]
-	if !ok {
-		return errors.New("fsm: next state not found: " + transition.NextState)
+	if _, err := f.setState(ctx, entityID, next, x, version); err != nil {
+		return &TransitionError{EntityID: entityID, From: current, To: next, Event: event.Name(), Err: err}
 	}
 
-	if err := currentState.OnExit(ctx, event); err != nil {
-		return err
+	if err := f.saveHistory(ctx, r); err != nil {
+		f.logger.Errorf("FSM [%s]: failed to store history states: %v", entityID, err)
 	}
 
-	if err := nextState.OnEnter(ctx, event); err != nil {
-		return err
+	if !changed {
+		f.logger.Infof("FSM [%s]: extended state updated", entityID)
+		return nil
+	}
+
+	result.To = next
+	result.Changed = true
+
+	f.report(ctx, entityID, steps, config)
+
+	if f.isCompleted(config) {
+		f.complete(ctx, entityID, next, steps[len(steps)-1].event)
+	}
+
+	return nil
+}
+
+// report logs, records and hooks every step that changed the configuration,
+// then updates the timers of the states exited and entered.
+func (f *FSM) report(ctx context.Context, entityID string, steps []step, config configuration) {
+	for _, st := range steps {
+		if !st.changed {
+			continue
+		}
+
+		if st.from == "" {
+			f.logger.Infof("FSM [%s]: initialized in state '%s'", entityID, st.to)
+		} else {
+			f.logger.Infof("FSM [%s]: transitioned %s → %s", entityID, st.from, st.to)
+		}
+		f.record(ctx, entityID, st.from, st.to, st.event)
+
+		if f.transitionHook != nil {
+			f.transitionHook(withTransitionOutput(ctx, st.output), entityID, st.from, st.to, st.event)
+		}
 	}
 
-	if err := f.storage.SetState(ctx, entityID, nextState.Name()); err != nil {
+	var exited, entered []string
+	for _, st := range steps {
+		exited = append(exited, st.exited...)
+		entered = append(entered, st.entered...)
+	}
+	f.updateTimers(ctx, entityID, exited, entered, config)
+}
+
+// complete runs once an entity has been stored in a final state. Storage
+// clean-up failures are logged but do not fail the transition.
+func (f *FSM) complete(ctx context.Context, entityID, state string, event Event) {
+	f.logger.Infof("FSM [%s]: completed in state '%s'", entityID, state)
+
+	if cs, ok := f.storage.(CompletableStorage); ok {
+		if err := cs.Complete(ctx, entityID, state); err != nil {
+			f.logger.Errorf("FSM [%s]: failed to complete entity in storage: %v", entityID, err)
+		}
+	}
+
+	if f.completionHook != nil {
+		f.completionHook(ctx, entityID, state, event)
+	}
+}
+
+// record appends the transition to the HistoryStore. Failures are logged
+// but do not fail the transition, which is already stored.
+func (f *FSM) record(ctx context.Context, entityID, from, to string, event Event) {
+	if f.history == nil {
+		return
+	}
+	record := TransitionRecord{
+		EntityID:  entityID,
+		Timestamp: time.Now(),
+		Event:     event.Name(),
+		Payload:   event.Payload(),
+		From:      from,
+		To:        to,
+		Actor:     ActorFromContext(ctx),
+	}
+	if err := f.history.Append(ctx, record); err != nil {
+		f.logger.Errorf("FSM [%s]: failed to record transition: %v", entityID, err)
+	}
+}
+
+// History returns the transitions recorded for the entity, oldest first.
+func (f *FSM) History(ctx context.Context, entityID string) ([]TransitionRecord, error) {
+	if f.history == nil {
+		return nil, errors.New("fsm: no HistoryStore configured")
+	}
+	return f.history.History(ctx, entityID)
+}
+
+// Init creates the entity in the initial state, running its OnEnter. It fails
+// with ErrEntityExists if the entity already has a state.
+func (f *FSM) Init(ctx context.Context, entityID string) error {
+	if f.initialState == "" {
+		return errors.New("fsm: no initial state configured")
+	}
+
+	if f.lockableStorage != nil {
+		unlock, _, err := f.lock(ctx, entityID, NewBasicEvent(InitEvent, nil))
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	_, err := f.storage.GetState(ctx, entityID)
+	if err == nil {
+		return fmt.Errorf("%w: '%s'", ErrEntityExists, entityID)
+	}
+	if !errors.Is(err, ErrEntityNotFound) {
 		return err
 	}
 
-	f.logger.Infof("FSM [%s]: transitioned %s → %s", entityID, currentStateName, nextState.Name())
+	_, _, err = f.initialize(ctx, entityID)
+	if errors.Is(err, ErrVersionConflict) {
+		return fmt.Errorf("%w: '%s'", ErrEntityExists, entityID)
+	}
+	return err
+}
+
+// initialize must be called with the entity lock held, if any. It returns
+// the initial configuration and, in optimistic mode, its version.
+func (f *FSM) initialize(ctx context.Context, entityID string) (string, int64, error) {
+	event := NewBasicEvent(InitEvent, nil)
+	entries := f.entrySet("", []string{f.initialState})
+	config := f.apply(nil, plannedTransition{entries: entries})
+	value := f.formatConfiguration(config)
+
+	ctx = withEntityID(ctx, entityID)
+
+	x, ok := extendedStateFrom(ctx)
+	if _, ds := f.storage.(DataStorage); ds && !ok {
+		x = &extendedState{}
+		ctx = withExtendedState(ctx, x)
+	}
+
+	ctx, err := f.loadEntity(ctx, entityID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	r := newRun(entityID)
+	ctx = withRun(ctx, r)
+
+	for _, name := range entries {
+		if err := f.states[name].OnEnter(ctx, event); err != nil {
+			return "", 0, &TransitionError{
+				EntityID: entityID,
+				To:       value,
+				Event:    InitEvent,
+				Err:      fmt.Errorf("%w: OnEnter of '%s': %w", ErrHookFailed, name, err),
+			}
+		}
+	}
+	r.queue = append(r.queue, f.doneEvents(entries, config)...)
+
+	config, internal, err := f.drain(ctx, r, config, "", event)
+	if err != nil {
+		return "", 0, err
+	}
+	steps := append([]step{{event: event, to: value, changed: true, entered: entries}}, internal...)
+	value = f.formatConfiguration(config)
+
+	version, err := f.setState(ctx, entityID, value, x, 0)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := f.saveHistory(ctx, r); err != nil {
+		f.logger.Errorf("FSM [%s]: failed to store history states: %v", entityID, err)
+	}
+
+	f.report(ctx, entityID, steps, config)
+
+	if f.isCompleted(config) {
+		f.complete(ctx, entityID, value, steps[len(steps)-1].event)
+	}
+
+	return value, version, nil
+}
+
+func (f *FSM) lock(ctx context.Context, entityID string, event Event) (func(), int, error) {
+	var unlock func()
+	var err error
+
+	cfg := f.lockRetry
+	backoff := cfg.backoff()
+	start := time.Now()
+
+	var delay time.Duration
+	attempts := 0
+	for attempt := 0; attempt <= cfg.MaxRetries; attempt++ {
+		attempts++
+		unlock, err = f.lockableStorage.Lock(ctx, entityID)
+		if err == nil {
+			if attempt > 0 {
+				f.logger.Infof("FSM [%s]: lock acquired after %d attempt(s)", entityID, attempt+1)
+			}
+			break
+		}
+
+		if cfg.MaxRetries == 0 {
+			f.logger.Infof("FSM [%s]: lock failed. No retries set.", entityID)
+			break
+		}
+		if attempt == cfg.MaxRetries {
+			break
+		}
+
+		delay = backoff.Delay(attempt, delay)
+		if cfg.MaxDelay > 0 && delay > cfg.MaxDelay {
+			delay = cfg.MaxDelay
+		}
+		if cfg.Deadline > 0 && time.Since(start)+delay > cfg.Deadline {
+			f.logger.Infof("FSM [%s]: lock attempt %d failed, retry deadline of %s reached", entityID, attempt+1, cfg.Deadline)
+			break
+		}
+
+		f.logger.Infof("FSM [%s]: lock attempt %d failed, retrying in %s", entityID, attempt+1, delay)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, attempts, err
+		}
+	}
 
-	if f.transitionHook != nil {
-		f.transitionHook(ctx, entityID, currentStateName, nextState.Name(), event)
+	if err != nil {
+		f.logger.Errorf("FSM [%s]: failed to acquire lock after retries: %v", entityID, err)
+		if f.lockFailureHandler != nil {
+			f.lockFailureHandler(ctx, entityID, event)
+		}
+		if !errors.Is(err, ErrLockNotAcquired) {
+			err = fmt.Errorf("%w for entity '%s': %w", ErrLockNotAcquired, entityID, err)
+		}
+		return nil, attempts, err
 	}
 
+	return unlock, attempts, nil
+}
+
+func (f *FSM) checkGuard(ctx context.Context, entityID, state, target string, guard Guard, event Event) error {
+	if target == "" {
+		target = state
+	}
+	ok, replaying := replayedGuard(ctx, target)
+	var err error
+	if !replaying {
+		ok, err = guard(ctx, entityID, event)
+	}
+	recordGuard(ctx, GuardResult{State: state, Event: event.Name(), Target: target, Passed: ok, Err: err})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return guardRejected(event, state)
+	}
 	return nil
 }
 
@@ -50,13 +50,17 @@ func TestFSM_Trigger_WithAutoLock_Retry(t *testing.T) {
 	}
 
 	event := NewBasicEvent("go", nil)
-	if err := fsm.Trigger(ctx, entityID, event); err != nil {
+	result, err := fsm.TriggerWithResult(ctx, entityID, event)
+	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
 	if fakeLockStorage.calledTimes != 3 {
 		t.Errorf("expected 3 attempts, got %d", fakeLockStorage.calledTimes)
 	}
+	if result.LockAttempts != 3 {
+		t.Errorf("expected result to report 3 lock attempts, got %d", result.LockAttempts)
+	}
 }
 
 func TestFSM_Trigger_WithAutoLock_FailureHandler(t *testing.T) {
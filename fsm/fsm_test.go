@@ -9,17 +9,18 @@ import (
 )
 
 type FakeStorage struct {
-	states map[string]string
+	states  map[string]string
+	history map[string]map[string]string
 }
 
 func NewFakeStorage() *FakeStorage {
-	return &FakeStorage{states: make(map[string]string)}
+	return &FakeStorage{states: make(map[string]string), history: make(map[string]map[string]string)}
 }
 
 func (s *FakeStorage) GetState(ctx context.Context, entityID string) (string, error) {
 	state, ok := s.states[entityID]
 	if !ok {
-		return "", errors.New("state not found")
+		return "", ErrEntityNotFound
 	}
 	return state, nil
 }
@@ -29,6 +30,20 @@ func (s *FakeStorage) SetState(ctx context.Context, entityID, state string) erro
 	return nil
 }
 
+func (s *FakeStorage) GetHistoryStates(ctx context.Context, entityID string) (map[string]string, error) {
+	return s.history[entityID], nil
+}
+
+func (s *FakeStorage) SetHistoryStates(ctx context.Context, entityID string, states map[string]string) error {
+	if s.history[entityID] == nil {
+		s.history[entityID] = make(map[string]string)
+	}
+	for parent, value := range states {
+		s.history[entityID][parent] = value
+	}
+	return nil
+}
+
 type MockLogger struct {
 	logs []string
 }
@@ -220,3 +235,65 @@ func TestFSM_TransitionHook(t *testing.T) {
 		t.Errorf("unexpected hook values: %+v", recorder)
 	}
 }
+
+func TestFSM_TriggerWithResult(t *testing.T) {
+	cases := []struct {
+		name          string
+		current       *TransitioningState
+		expectErr     bool
+		expectFrom    string
+		expectTo      string
+		expectChanged bool
+	}{
+		{
+			name:          "transition",
+			current:       &TransitioningState{name: "init", nextStateName: "done"},
+			expectFrom:    "init",
+			expectTo:      "done",
+			expectChanged: true,
+		},
+		{
+			name:          "no state change",
+			current:       &TransitioningState{name: "init", nextStateName: "init"},
+			expectFrom:    "init",
+			expectTo:      "init",
+			expectChanged: false,
+		},
+		{
+			name:          "handle error",
+			current:       &TransitioningState{name: "init", failOnHandle: true},
+			expectErr:     true,
+			expectFrom:    "init",
+			expectTo:      "init",
+			expectChanged: false,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			entityID := "result-test"
+			storage := NewFakeStorage()
+			storage.SetState(ctx, entityID, "init")
+
+			fsm, err := NewFSM([]State{tt.current, &TransitioningState{name: "done"}},
+				WithStateStorage(storage),
+				WithLogger(&MockLogger{}),
+			)
+			if err != nil {
+				t.Fatalf("failed to create FSM: %v", err)
+			}
+
+			result, err := fsm.TriggerWithResult(ctx, entityID, NewBasicEvent("finish", nil))
+			if tt.expectErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.From != tt.expectFrom || result.To != tt.expectTo || result.Changed != tt.expectChanged {
+				t.Errorf("unexpected result: %+v", result)
+			}
+			if result.LockAttempts != 0 {
+				t.Errorf("expected no lock attempts, got %d", result.LockAttempts)
+			}
+		})
+	}
+}
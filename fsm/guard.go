@@ -0,0 +1,55 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Guard decides whether a transition may happen. Returning false vetoes the
+// transition with ErrGuardRejected; returning an error aborts the trigger.
+type Guard func(ctx context.Context, entityID string, event Event) (bool, error)
+
+// And passes only when every guard passes. Evaluation stops at the first
+// rejection or error.
+func And(guards ...Guard) Guard {
+	return func(ctx context.Context, entityID string, event Event) (bool, error) {
+		for _, g := range guards {
+			ok, err := g(ctx, entityID, event)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+}
+
+// Or passes when at least one guard passes. Evaluation stops at the first
+// success or error.
+func Or(guards ...Guard) Guard {
+	return func(ctx context.Context, entityID string, event Event) (bool, error) {
+		for _, g := range guards {
+			ok, err := g(ctx, entityID, event)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+func Not(guard Guard) Guard {
+	return func(ctx context.Context, entityID string, event Event) (bool, error) {
+		ok, err := guard(ctx, entityID, event)
+		if err != nil {
+			return false, err
+		}
+		return !ok, nil
+	}
+}
+
+func guardRejected(event Event, state string) error {
+	return fmt.Errorf("%w: event '%s' in state '%s'", ErrGuardRejected, event.Name(), state)
+}
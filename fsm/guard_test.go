@@ -0,0 +1,138 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func allow(ctx context.Context, entityID string, event Event) (bool, error) { return true, nil }
+func deny(ctx context.Context, entityID string, event Event) (bool, error)  { return false, nil }
+func broken(ctx context.Context, entityID string, event Event) (bool, error) {
+	return false, errors.New("guard error")
+}
+
+func TestGuard_Combinators(t *testing.T) {
+	cases := []struct {
+		name      string
+		guard     Guard
+		expectOK  bool
+		expectErr bool
+	}{
+		{name: "and all pass", guard: And(allow, allow), expectOK: true},
+		{name: "and one denies", guard: And(allow, deny), expectOK: false},
+		{name: "and short-circuits", guard: And(deny, broken), expectOK: false},
+		{name: "or one passes", guard: Or(deny, allow), expectOK: true},
+		{name: "or none pass", guard: Or(deny, deny), expectOK: false},
+		{name: "or error", guard: Or(deny, broken), expectErr: true},
+		{name: "not", guard: Not(deny), expectOK: true},
+		{name: "not error", guard: Not(broken), expectErr: true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := tt.guard(context.Background(), "entity", NewBasicEvent("e", nil))
+			if tt.expectErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tt.expectOK {
+				t.Errorf("expected %v, got %v", tt.expectOK, ok)
+			}
+		})
+	}
+}
+
+func TestFSM_Trigger_Guards(t *testing.T) {
+	cases := []struct {
+		name           string
+		def            *Definition
+		expectErr      error
+		expectNewState string
+	}{
+		{
+			name:           "guard passes",
+			def:            NewDefinition().From("start").On("go").Guard(allow).To("done"),
+			expectNewState: "done",
+		},
+		{
+			name:           "guard rejects",
+			def:            NewDefinition().From("start").On("go").Guard(deny).To("done"),
+			expectErr:      ErrGuardRejected,
+			expectNewState: "start",
+		},
+		{
+			name: "first passing guard wins",
+			def: NewDefinition().
+				From("start").On("go").Guard(deny).To("rejected").
+				From("start").On("go").Guard(allow).To("accepted"),
+			expectNewState: "accepted",
+		},
+		{
+			name:           "chained guards are combined",
+			def:            NewDefinition().From("start").On("go").Guard(allow).Guard(deny).To("done"),
+			expectErr:      ErrGuardRejected,
+			expectNewState: "start",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			entityID := "entity-guard"
+			storage := NewFakeStorage()
+			storage.SetState(ctx, entityID, "start")
+
+			fsm, err := NewFSM(tt.def.States(), WithStateStorage(storage), WithLogger(&MockLogger{}))
+			if err != nil {
+				t.Fatalf("failed to create FSM: %v", err)
+			}
+
+			err = fsm.Trigger(ctx, entityID, NewBasicEvent("go", nil))
+			if tt.expectErr == nil && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.expectErr != nil && !errors.Is(err, tt.expectErr) {
+				t.Errorf("expected error %v, got %v", tt.expectErr, err)
+			}
+
+			state, _ := storage.GetState(ctx, entityID)
+			if state != tt.expectNewState {
+				t.Errorf("expected state %q, got %q", tt.expectNewState, state)
+			}
+		})
+	}
+}
+
+func TestFSM_Trigger_GuardBeforeExit(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-guard-exit"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "start")
+
+	var calls []string
+	var guardEntity string
+	guard := func(ctx context.Context, id string, event Event) (bool, error) {
+		guardEntity = id
+		calls = append(calls, "guard")
+		return false, nil
+	}
+
+	def := NewDefinition().
+		Add(&LifecycleRecorder{name: "start", calls: &calls}).
+		From("start").On("go").Guard(guard).To("done")
+
+	fsm, err := NewFSM(def.States(), WithStateStorage(storage), WithLogger(&MockLogger{}))
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("go", nil)); !errors.Is(err, ErrGuardRejected) {
+		t.Fatalf("expected ErrGuardRejected, got %v", err)
+	}
+	if len(calls) != 1 || calls[0] != "guard" {
+		t.Errorf("expected only the guard to run, got %v", calls)
+	}
+	if guardEntity != entityID {
+		t.Errorf("expected guard to receive entity %q, got %q", entityID, guardEntity)
+	}
+}
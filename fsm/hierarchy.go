@@ -0,0 +1,243 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// PathSeparator joins the names of a nested state and its ancestors in the
+// value persisted to StateStorage, e.g. "active/running".
+const PathSeparator = "/"
+
+// RegionSeparator joins the paths of the active leaves when an entity is in
+// a parallel state, e.g. "scan/jobs/running,scan/billing/pending".
+const RegionSeparator = ","
+
+func (f *FSM) declared(state string) (*declaredState, bool) {
+	s, ok := f.states[state].(*declaredState)
+	return s, ok
+}
+
+func (f *FSM) parentOf(state string) string {
+	if s, ok := f.declared(state); ok {
+		return s.parent
+	}
+	return ""
+}
+
+func (f *FSM) childrenOf(state string) []string {
+	if s, ok := f.declared(state); ok {
+		return s.children
+	}
+	return nil
+}
+
+func (f *FSM) isParallel(state string) bool {
+	s, ok := f.declared(state)
+	return ok && s.parallel
+}
+
+func (f *FSM) isFinal(state string) bool {
+	s, ok := f.declared(state)
+	return ok && s.final
+}
+
+// lineage returns the state followed by its ancestors, innermost first.
+func (f *FSM) lineage(state string) []string {
+	var states []string
+	for s := state; s != ""; s = f.parentOf(s) {
+		states = append(states, s)
+	}
+	return states
+}
+
+func (f *FSM) isAncestor(ancestor, state string) bool {
+	for s := f.parentOf(state); s != ""; s = f.parentOf(s) {
+		if s == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// indexStates records the depth-first position of every state, which is the
+// order used to enter states and to persist parallel leaves.
+func (f *FSM) indexStates(states []State) {
+	f.docOrder = make(map[string]int, len(states))
+	var visit func(name string)
+	visit = func(name string) {
+		if _, seen := f.docOrder[name]; seen {
+			return
+		}
+		f.docOrder[name] = len(f.docOrder)
+		for _, child := range f.childrenOf(name) {
+			visit(child)
+		}
+	}
+	for _, s := range states {
+		if s != nil && f.parentOf(s.Name()) == "" {
+			visit(s.Name())
+		}
+	}
+}
+
+func (f *FSM) sortStates(states []string) {
+	sort.SliceStable(states, func(i, j int) bool {
+		return f.docOrder[states[i]] < f.docOrder[states[j]]
+	})
+}
+
+// pathOf returns the persisted representation of a leaf state.
+func (f *FSM) pathOf(leaf string) string {
+	lineage := f.lineage(leaf)
+	names := make([]string, len(lineage))
+	for i, s := range lineage {
+		names[len(lineage)-1-i] = s
+	}
+	return strings.Join(names, PathSeparator)
+}
+
+// leafOf extracts the leaf state name from a persisted path. Values written
+// before a state was nested are plain names and are returned unchanged, as
+// are the names of flat machines that contain PathSeparator.
+func (f *FSM) leafOf(path string) string {
+	if _, ok := f.states[path]; ok {
+		return path
+	}
+	if i := strings.LastIndex(path, PathSeparator); i >= 0 {
+		return path[i+len(PathSeparator):]
+	}
+	return path
+}
+
+// domain returns the innermost non-parallel state that contains both source
+// and target without being either of them, or "" for the root.
+func (f *FSM) domain(source, target string) string {
+	for s := f.parentOf(source); s != ""; s = f.parentOf(s) {
+		if !f.isParallel(s) && f.isAncestor(s, target) {
+			return s
+		}
+	}
+	return ""
+}
+
+// exitSet lists the active states below domain, innermost first. These are
+// the states left by a transition whose domain it is.
+func (f *FSM) exitSet(config configuration, domain string) []string {
+	seen := make(map[string]bool)
+	var states []string
+	for _, leaf := range config {
+		if domain != "" && !f.isAncestor(domain, leaf) {
+			continue
+		}
+		for s := leaf; s != domain; s = f.parentOf(s) {
+			if !seen[s] {
+				seen[s] = true
+				states = append(states, s)
+			}
+		}
+	}
+	f.sortStates(states)
+	for i, j := 0, len(states)-1; i < j; i, j = i+1, j-1 {
+		states[i], states[j] = states[j], states[i]
+	}
+	return states
+}
+
+// entrySet lists the states entered below domain to reach every target,
+// outermost first. Composite states not on the way to a target continue into
+// their initial child and parallel states into every region.
+func (f *FSM) entrySet(domain string, targets []string) []string {
+	wanted := make(map[string]bool)
+	var tops []string
+	for _, target := range targets {
+		for s := target; s != domain && s != ""; s = f.parentOf(s) {
+			if !wanted[s] && f.parentOf(s) == domain {
+				tops = append(tops, s)
+			}
+			wanted[s] = true
+		}
+	}
+	f.sortStates(tops)
+
+	var states []string
+	var enter func(state string)
+	enter = func(state string) {
+		states = append(states, state)
+		children := f.childrenOf(state)
+		if f.isParallel(state) {
+			for _, child := range children {
+				enter(child)
+			}
+			return
+		}
+		for _, child := range children {
+			if wanted[child] {
+				enter(child)
+				return
+			}
+		}
+		if len(children) > 0 {
+			enter(children[0])
+		}
+	}
+	for _, top := range tops {
+		enter(top)
+	}
+	return states
+}
+
+// handle offers the event to the leaf and then to each ancestor until one of
+// them provides a transition. It returns the transition and the state that
+// owns it. Guard rejections and missing transitions bubble up; if no ancestor
+// handles the event, the first rejection or missing transition is reported.
+func (f *FSM) handle(ctx context.Context, entityID, leaf string, event Event) (Transition, string, error) {
+	var rejected, unhandled error
+	for _, name := range f.lineage(leaf) {
+		transition, err := f.states[name].HandleEvent(ctx, event)
+		if err == nil && transition.Guard != nil {
+			err = f.checkGuard(ctx, entityID, name, transition.NextState, transition.Guard, event)
+		}
+		switch {
+		case err == nil:
+			return transition, name, nil
+		case errors.Is(err, ErrGuardRejected):
+			if rejected == nil {
+				rejected = err
+			}
+		case errors.Is(err, ErrNoTransition):
+			if unhandled == nil {
+				unhandled = err
+			}
+		default:
+			return Transition{}, name, err
+		}
+	}
+
+	if rejected != nil {
+		return Transition{}, leaf, rejected
+	}
+	return Transition{}, leaf, unhandled
+}
+
+// isDone reports whether a composite state has reached one of its final
+// children, or, for a parallel state, whether every region has.
+func (f *FSM) isDone(state string, active map[string]bool) bool {
+	children := f.childrenOf(state)
+	if f.isParallel(state) {
+		for _, child := range children {
+			if !f.isDone(child, active) {
+				return false
+			}
+		}
+		return len(children) > 0
+	}
+	for _, child := range children {
+		if active[child] && f.isFinal(child) {
+			return true
+		}
+	}
+	return false
+}
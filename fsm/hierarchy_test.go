@@ -0,0 +1,196 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func newHierarchyDefinition(calls *[]string) *Definition {
+	return NewDefinition().
+		Add(
+			&LifecycleRecorder{name: "idle", calls: calls},
+			&LifecycleRecorder{name: "active", calls: calls},
+			&LifecycleRecorder{name: "running", calls: calls},
+			&LifecycleRecorder{name: "paused", calls: calls},
+			&LifecycleRecorder{name: "cancelled", calls: calls},
+		).
+		Composite("active", "running", "paused").
+		From("idle").On("start").To("active").
+		From("running").On("pause").To("paused").
+		From("paused").On("resume").To("running").
+		From("active").On("cancel").To("cancelled").
+		From("active").On("restart").To("active")
+}
+
+func TestFSM_Hierarchy(t *testing.T) {
+	cases := []struct {
+		name          string
+		initialState  string
+		event         string
+		expectState   string
+		expectCalls   []string
+		expectChanged bool
+		expectErr     error
+	}{
+		{
+			name:          "entering composite enters initial child",
+			initialState:  "idle",
+			event:         "start",
+			expectState:   "active/running",
+			expectCalls:   []string{"exit:idle", "enter:active", "enter:running"},
+			expectChanged: true,
+		},
+		{
+			name:          "sibling transition stays in parent",
+			initialState:  "active/running",
+			event:         "pause",
+			expectState:   "active/paused",
+			expectCalls:   []string{"exit:running", "enter:paused"},
+			expectChanged: true,
+		},
+		{
+			name:          "unhandled event bubbles to parent",
+			initialState:  "active/paused",
+			event:         "cancel",
+			expectState:   "cancelled",
+			expectCalls:   []string{"exit:paused", "exit:active", "enter:cancelled"},
+			expectChanged: true,
+		},
+		{
+			name:          "self transition on parent re-enters it",
+			initialState:  "active/paused",
+			event:         "restart",
+			expectState:   "active/running",
+			expectCalls:   []string{"exit:paused", "exit:active", "enter:active", "enter:running"},
+			expectChanged: true,
+		},
+		{
+			name:          "self transition on active parent re-enters it",
+			initialState:  "active/running",
+			event:         "restart",
+			expectState:   "active/running",
+			expectCalls:   []string{"exit:running", "exit:active", "enter:active", "enter:running"},
+			expectChanged: true,
+		},
+		{
+			name:         "event unhandled at every level",
+			initialState: "active/running",
+			event:        "resume",
+			expectState:  "active/running",
+			expectErr:    ErrNoTransition,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			entityID := "entity-hierarchy"
+			storage := NewFakeStorage()
+			storage.SetState(ctx, entityID, tt.initialState)
+
+			var calls []string
+			hooked := false
+			hook := func(ctx context.Context, entityID, from, to string, event Event) {
+				hooked = true
+			}
+			fsm, err := NewFSM(newHierarchyDefinition(&calls).States(),
+				WithStateStorage(storage),
+				WithLogger(&MockLogger{}),
+				WithTransitionHook(hook),
+			)
+			if err != nil {
+				t.Fatalf("failed to create FSM: %v", err)
+			}
+
+			result, err := fsm.TriggerWithResult(ctx, entityID, NewBasicEvent(tt.event, nil))
+			if tt.expectErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.expectErr != nil && !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+			}
+
+			state, _ := storage.GetState(ctx, entityID)
+			if state != tt.expectState {
+				t.Errorf("expected state %q, got %q", tt.expectState, state)
+			}
+			if result.Changed != tt.expectChanged || hooked != tt.expectChanged {
+				t.Errorf("expected changed %v, got result %v and hook %v", tt.expectChanged, result.Changed, hooked)
+			}
+
+			if len(calls) != len(tt.expectCalls) {
+				t.Fatalf("expected calls %v, got %v", tt.expectCalls, calls)
+			}
+			for i := range calls {
+				if calls[i] != tt.expectCalls[i] {
+					t.Fatalf("expected calls %v, got %v", tt.expectCalls, calls)
+				}
+			}
+		})
+	}
+}
+
+func TestFSM_Hierarchy_Init(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-hierarchy-init"
+	storage := NewFakeStorage()
+
+	var calls []string
+	fsm, err := NewFSM(newHierarchyDefinition(&calls).States(),
+		WithStateStorage(storage),
+		WithLogger(&MockLogger{}),
+		WithInitialState("active"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+
+	if err := fsm.Init(ctx, entityID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	state, _ := fsm.CurrentState(ctx, entityID)
+	if state != "active/running" {
+		t.Errorf("expected state %q, got %q", "active/running", state)
+	}
+	if len(calls) != 2 || calls[0] != "enter:active" || calls[1] != "enter:running" {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+}
+
+func TestFSM_FlatStateNamesWithSeparator(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-flat"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "scan/pending")
+
+	def := NewDefinition().
+		From("scan/pending").On("start").To("scan/running")
+
+	fsm, err := NewFSM(def.States(), WithStateStorage(storage), WithLogger(&MockLogger{}))
+	if err != nil {
+		t.Fatalf("unexpected error creating FSM: %v", err)
+	}
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("start", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state, _ := storage.GetState(ctx, entityID); state != "scan/running" {
+		t.Errorf("expected state 'scan/running', got %s", state)
+	}
+}
+
+func TestNewFSM_RejectsSeparatorInNestedMachine(t *testing.T) {
+	def := NewDefinition().
+		Composite("scan", "scan/pending", "done").
+		From("scan/pending").On("finish").To("done")
+
+	_, err := NewFSM(def.States(), WithStateStorage(NewFakeStorage()), WithLogger(&MockLogger{}))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	if len(verr.Problems) != 1 || verr.Problems[0].Kind != InvalidName || verr.Problems[0].State != "scan/pending" {
+		t.Errorf("unexpected problems: %v", verr.Problems)
+	}
+}
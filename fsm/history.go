@@ -0,0 +1,92 @@
+package fsm
+
+import "context"
+
+type HistoryKind int
+
+const (
+	// ShallowHistory restores the child that was active when its parent was
+	// last exited, entering that child's initial state.
+	ShallowHistory HistoryKind = iota + 1
+	// DeepHistory restores every active leaf below the parent.
+	DeepHistory
+)
+
+func (f *FSM) historyKind(state string) HistoryKind {
+	if s, ok := f.declared(state); ok {
+		return s.history
+	}
+	return 0
+}
+
+func (f *FSM) hasHistory(state string) bool {
+	s, ok := f.declared(state)
+	return ok && s.hasHistory
+}
+
+// remember records the configuration below every exited state that has a
+// history pseudo-state.
+func (f *FSM) remember(r *run, config configuration, exits []string) {
+	for _, s := range exits {
+		if !f.hasHistory(s) {
+			continue
+		}
+		var leaves configuration
+		for _, leaf := range config {
+			if f.isAncestor(s, leaf) {
+				leaves = append(leaves, leaf)
+			}
+		}
+		r.history[s] = f.formatConfiguration(leaves)
+	}
+}
+
+// restore resolves a history pseudo-state into the states to enter. Without
+// a remembered configuration the parent's initial child is used.
+func (f *FSM) restore(ctx context.Context, r *run, pseudo string) ([]string, error) {
+	parent := f.parentOf(pseudo)
+
+	value, ok := r.history[parent]
+	if hs, stored := f.storage.(HistoryStateStorage); !ok && stored && !r.dryRun {
+		remembered, err := hs.GetHistoryStates(ctx, r.entityID)
+		if err != nil {
+			return nil, err
+		}
+		value, ok = remembered[parent]
+	}
+
+	var targets []string
+	if ok && value != "" {
+		for _, leaf := range f.parseConfiguration(value) {
+			if _, known := f.states[leaf]; !known || !f.isAncestor(parent, leaf) {
+				continue
+			}
+			if f.historyKind(pseudo) == ShallowHistory {
+				for f.parentOf(leaf) != parent {
+					leaf = f.parentOf(leaf)
+				}
+			}
+			targets = append(targets, leaf)
+		}
+	}
+
+	if len(targets) == 0 {
+		if children := f.childrenOf(parent); len(children) > 0 {
+			targets = children[:1]
+		} else {
+			targets = []string{parent}
+		}
+	}
+	return targets, nil
+}
+
+// saveHistory persists the configurations remembered during the run. It is
+// called once the entity's state has been stored, so that a failed write
+// never leaves behind the history of a transition that did not happen.
+func (f *FSM) saveHistory(ctx context.Context, r *run) error {
+	hs, ok := f.storage.(HistoryStateStorage)
+	if !ok || len(r.history) == 0 {
+		return nil
+	}
+	return hs.SetHistoryStates(ctx, r.entityID, r.history)
+}
@@ -0,0 +1,153 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func newHistoryDefinition() *Definition {
+	return NewDefinition().
+		Composite("active", "running", "waiting").
+		Composite("running", "fetching", "parsing").
+		History("active_shallow", "active", ShallowHistory).
+		History("active_deep", "active", DeepHistory).
+		From("fetching").On("fetched").To("parsing").
+		From("running").On("wait").To("waiting").
+		From("active").On("pause").To("paused").
+		From("paused").On("resume").To("active_deep").
+		From("paused").On("restart").To("active_shallow")
+}
+
+func TestFSM_History(t *testing.T) {
+	cases := []struct {
+		name        string
+		events      []string
+		expectState string
+	}{
+		{
+			name:        "deep history restores the leaf",
+			events:      []string{"fetched", "pause", "resume"},
+			expectState: "active/running/parsing",
+		},
+		{
+			name:        "shallow history restores the child",
+			events:      []string{"fetched", "pause", "restart"},
+			expectState: "active/running/fetching",
+		},
+		{
+			name:        "history follows the last exit",
+			events:      []string{"wait", "pause", "resume"},
+			expectState: "active/waiting",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			entityID := "entity-history"
+			storage := NewFakeStorage()
+			storage.SetState(ctx, entityID, "active/running/fetching")
+
+			fsm, err := NewFSM(newHistoryDefinition().States(),
+				WithStateStorage(storage),
+				WithLogger(&MockLogger{}),
+			)
+			if err != nil {
+				t.Fatalf("failed to create FSM: %v", err)
+			}
+
+			for _, name := range tt.events {
+				if err := fsm.Trigger(ctx, entityID, NewBasicEvent(name, nil)); err != nil {
+					t.Fatalf("unexpected error on %q: %v", name, err)
+				}
+			}
+
+			state, _ := storage.GetState(ctx, entityID)
+			if state != tt.expectState {
+				t.Errorf("expected state %q, got %q", tt.expectState, state)
+			}
+		})
+	}
+}
+
+func TestFSM_History_Persisted(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-history-persisted"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "active/running/parsing")
+
+	first, err := NewFSM(newHistoryDefinition().States(), WithStateStorage(storage), WithLogger(&MockLogger{}))
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+	if err := first.Trigger(ctx, entityID, NewBasicEvent("pause", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if remembered := storage.history[entityID]["active"]; remembered != "active/running/parsing" {
+		t.Errorf("expected remembered configuration %q, got %q", "active/running/parsing", remembered)
+	}
+
+	second, err := NewFSM(newHistoryDefinition().States(), WithStateStorage(storage), WithLogger(&MockLogger{}))
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+	if err := second.Trigger(ctx, entityID, NewBasicEvent("resume", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	state, _ := storage.GetState(ctx, entityID)
+	if state != "active/running/parsing" {
+		t.Errorf("expected state %q, got %q", "active/running/parsing", state)
+	}
+	if _, err := storage.GetState(ctx, entityID+":history:active"); err == nil {
+		t.Error("expected history not to be stored as an entity")
+	}
+}
+
+type failingStateStorage struct {
+	*FakeStorage
+}
+
+func (s *failingStateStorage) SetState(ctx context.Context, entityID, state string) error {
+	return errors.New("write failed")
+}
+
+func TestFSM_History_NotStoredOnFailedWrite(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-history-failed"
+	storage := &failingStateStorage{NewFakeStorage()}
+	storage.states[entityID] = "active/running/parsing"
+
+	fsm, err := NewFSM(newHistoryDefinition().States(), WithStateStorage(storage), WithLogger(&MockLogger{}))
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("pause", nil)); err == nil {
+		t.Fatal("expected write error")
+	}
+	if remembered, ok := storage.history[entityID]["active"]; ok {
+		t.Errorf("expected no remembered configuration, got %q", remembered)
+	}
+}
+
+func TestFSM_History_Default(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-history-default"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "paused")
+
+	fsm, err := NewFSM(newHistoryDefinition().States(), WithStateStorage(storage), WithLogger(&MockLogger{}))
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("resume", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	state, _ := storage.GetState(ctx, entityID)
+	if state != "active/running/fetching" {
+		t.Errorf("expected state %q, got %q", "active/running/fetching", state)
+	}
+}
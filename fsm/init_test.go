@@ -0,0 +1,111 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFSM_Init(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-init"
+	storage := NewFakeStorage()
+	recorder := &HookRecorder{}
+
+	var calls []string
+	def := NewDefinition().
+		Add(&LifecycleRecorder{name: "pending", calls: &calls}).
+		From("pending").On("start").To("running")
+
+	fsm, err := NewFSM(def.States(),
+		WithStateStorage(storage),
+		WithLogger(&MockLogger{}),
+		WithInitialState("pending"),
+		WithTransitionHook(recorder.Hook),
+	)
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+
+	if err := fsm.Init(ctx, entityID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	state, _ := storage.GetState(ctx, entityID)
+	if state != "pending" {
+		t.Errorf("expected state %q, got %q", "pending", state)
+	}
+	if len(calls) != 1 || calls[0] != "enter:pending" {
+		t.Errorf("expected initial OnEnter to run, got %v", calls)
+	}
+	if !recorder.called || recorder.from != "" || recorder.to != "pending" || recorder.event != InitEvent {
+		t.Errorf("unexpected hook values: %+v", recorder)
+	}
+
+	if err := fsm.Init(ctx, entityID); !errors.Is(err, ErrEntityExists) {
+		t.Errorf("expected ErrEntityExists, got %v", err)
+	}
+}
+
+func TestFSM_AutoInit(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-auto-init"
+	storage := NewFakeStorage()
+
+	def := NewDefinition().From("pending").On("start").To("running")
+
+	fsm, err := NewFSM(def.States(),
+		WithStateStorage(storage),
+		WithLogger(&MockLogger{}),
+		WithInitialState("pending"),
+		WithAutoInit(),
+	)
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+
+	result, err := fsm.TriggerWithResult(ctx, entityID, NewBasicEvent("start", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.From != "pending" || result.To != "running" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestNewFSM_InitialState(t *testing.T) {
+	storage := NewFakeStorage()
+	states := []State{&TransitioningState{name: "pending"}}
+
+	if _, err := NewFSM(states, WithStateStorage(storage), WithInitialState("ghost")); !errors.Is(err, ErrUnknownState) {
+		t.Errorf("expected ErrUnknownState, got %v", err)
+	}
+	if _, err := NewFSM(states, WithStateStorage(storage), WithAutoInit()); err == nil {
+		t.Error("expected error for auto-init without initial state")
+	}
+}
+
+func TestFSM_Init_WithAutoLock(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-init-lock"
+	lockStorage := &FakeLockStorage{StateStorage: NewFakeStorage(), failCount: 1}
+
+	fsm, err := NewFSM([]State{&TransitioningState{name: "pending"}},
+		WithLogger(&MockLogger{}),
+		WithInitialState("pending"),
+		WithAutoLock(lockStorage, LockRetryConfig{}, nil),
+	)
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+
+	if err := fsm.Init(ctx, entityID); !errors.Is(err, ErrLockNotAcquired) {
+		t.Fatalf("expected ErrLockNotAcquired, got %v", err)
+	}
+	if err := fsm.Init(ctx, entityID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lockStorage.calledTimes != 2 {
+		t.Errorf("expected 2 lock attempts, got %d", lockStorage.calledTimes)
+	}
+}
@@ -5,10 +5,14 @@ import (
 	"time"
 )
 
-type State interface {
+type Lifecycle interface {
 	Name() string
 	OnEnter(ctx context.Context, event Event) error
 	OnExit(ctx context.Context, event Event) error
+}
+
+type State interface {
+	Lifecycle
 	HandleEvent(ctx context.Context, event Event) (Transition, error)
 }
 
@@ -22,14 +26,103 @@ type StateStorage interface {
 	SetState(ctx context.Context, entityID, state string) error
 }
 
+// CompletableStorage is implemented by storages that archive or expire
+// entities once they reach a final state.
+type CompletableStorage interface {
+	StateStorage
+	Complete(ctx context.Context, entityID, state string) error
+}
+
+// Timer is a delayed event scheduled for an entity while it is in State.
+type Timer struct {
+	EntityID string
+	State    string
+	Event    string
+	FireAt   time.Time
+}
+
+// TimerStore keeps the delayed events of state timeouts durable. Due must
+// remove the timers it returns, so that each one is delivered once.
+type TimerStore interface {
+	Schedule(ctx context.Context, timer Timer) error
+	Cancel(ctx context.Context, timer Timer) error
+	Due(ctx context.Context, now time.Time) ([]Timer, error)
+}
+
+// HistoryStateStorage keeps, next to each entity, the configurations
+// remembered for history pseudo-states, keyed by their parent state.
+// GetHistoryStates returns an empty map for entities without any, and
+// SetHistoryStates replaces the given parents only. Without
+// it, history pseudo-states only remember what happened within a single
+// Trigger call.
+type HistoryStateStorage interface {
+	StateStorage
+	GetHistoryStates(ctx context.Context, entityID string) (map[string]string, error)
+	SetHistoryStates(ctx context.Context, entityID string, states map[string]string) error
+}
+
+// DataStorage keeps an entity's extended state, see LoadData, next to its
+// state. SetStateData must write both atomically.
+type DataStorage interface {
+	StateStorage
+	GetStateData(ctx context.Context, entityID string) (string, []byte, error)
+	SetStateData(ctx context.Context, entityID, state string, data []byte) error
+}
+
+// VersionedStorage stores a version with each state, which changes on every
+// write, for optimistic concurrency, see WithOptimisticConcurrency. Entities
+// that do not exist are at version 0. CompareAndSetState writes the state
+// only if the entity is still at the given version, and returns the new
+// version, or fails with ErrVersionConflict.
+type VersionedStorage interface {
+	StateStorage
+	GetStateVersion(ctx context.Context, entityID string) (string, int64, error)
+	CompareAndSetState(ctx context.Context, entityID, state string, version int64) (int64, error)
+}
+
+// VersionedDataStorage is a VersionedStorage that also keeps the extended
+// state. Without it, LoadData and SaveData are not available in optimistic
+// mode.
+type VersionedDataStorage interface {
+	VersionedStorage
+	DataStorage
+	GetStateDataVersion(ctx context.Context, entityID string) (string, []byte, int64, error)
+	CompareAndSetStateData(ctx context.Context, entityID, state string, data []byte, version int64) (int64, error)
+}
+
+// TransitionRecord is an entry of an entity's audit trail. Actor is taken
+// from the context passed to Trigger, see WithActor.
+type TransitionRecord struct {
+	EntityID  string
+	Timestamp time.Time
+	Event     string
+	Payload   any
+	From      string
+	To        string
+	Actor     string
+}
+
+// HistoryStore keeps the audit trail of every transition. History returns
+// the records of an entity, oldest first.
+type HistoryStore interface {
+	Append(ctx context.Context, record TransitionRecord) error
+	History(ctx context.Context, entityID string) ([]TransitionRecord, error)
+}
+
 type LockableStorage interface {
 	StateStorage
 	Lock(ctx context.Context, entityID string) (func(), error)
 }
 
+// LockRetryConfig controls how Trigger retries to acquire the entity lock.
+// Waiting stops as soon as the context is done.
 type LockRetryConfig struct {
 	MaxRetries      int           // número de tentativas antes de desistir
 	BackoffInterval time.Duration // intervalo base para o backoff (exponencial)
+
+	Backoff  BackoffStrategy // replaces the exponential backoff from BackoffInterval
+	MaxDelay time.Duration   // caps every wait, if set
+	Deadline time.Duration   // gives up once retrying would take longer, if set
 }
 
 type Logger interface {
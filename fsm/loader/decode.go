@@ -0,0 +1,92 @@
+package loader
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// field is a scalar value together with where it was read from.
+type field struct {
+	value string
+	node  *yaml.Node
+	path  string
+}
+
+func (f field) errorf(err error) *Error {
+	return errorAt(f.node, f.path, err)
+}
+
+func errorAt(n *yaml.Node, path string, err error) *Error {
+	e := &Error{Field: path, Err: err}
+	if n != nil {
+		e.Line, e.Column = n.Line, n.Column
+	}
+	return e
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func index(path string, i int) string {
+	return fmt.Sprintf("%s[%d]", path, i)
+}
+
+type fieldDecoder func(n *yaml.Node, path string) error
+
+// decodeMapping calls the decoder registered for every key of the mapping.
+// Unknown keys are reported as errors.
+func decodeMapping(n *yaml.Node, path string, fields map[string]fieldDecoder) error {
+	if n.Kind != yaml.MappingNode {
+		return errorAt(n, path, fmt.Errorf("%w: expected a mapping", ErrInvalidDocument))
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		decode, ok := fields[key.Value]
+		if !ok {
+			return errorAt(key, join(path, key.Value), fmt.Errorf("%w: unknown field", ErrInvalidDocument))
+		}
+		if err := decode(value, join(path, key.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeSequence(n *yaml.Node, path string, decode fieldDecoder) error {
+	if n.Kind != yaml.SequenceNode {
+		return errorAt(n, path, fmt.Errorf("%w: expected a list", ErrInvalidDocument))
+	}
+	for i, item := range n.Content {
+		if err := decode(item, index(path, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeString(target *field) fieldDecoder {
+	return func(n *yaml.Node, path string) error {
+		if n.Kind != yaml.ScalarNode {
+			return errorAt(n, path, fmt.Errorf("%w: expected a string", ErrInvalidDocument))
+		}
+		*target = field{value: n.Value, node: n, path: path}
+		return nil
+	}
+}
+
+func decodeBool(target *bool) fieldDecoder {
+	return func(n *yaml.Node, path string) error {
+		b, err := strconv.ParseBool(n.Value)
+		if n.Kind != yaml.ScalarNode || err != nil {
+			return errorAt(n, path, fmt.Errorf("%w: expected a boolean", ErrInvalidDocument))
+		}
+		*target = b
+		return nil
+	}
+}
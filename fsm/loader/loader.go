@@ -0,0 +1,393 @@
+// Package loader builds machines from YAML or JSON documents. Actions,
+// guards and state implementations are referenced by name and bound from a
+// Registry.
+//
+//	initial: pending
+//	events: [start, finish, expire]
+//	states:
+//	  - name: pending
+//	    timeout: {after: 10m, event: expire}
+//	  - name: running
+//	  - name: completed
+//	    final: true
+//	transitions:
+//	  - {from: pending, event: start, to: running, guard: hasTargets}
+//	  - {from: running, event: finish, to: completed, action: notify}
+//
+// Nested states are listed under "states" of their parent; "parallel: true"
+// makes every child a region and "history: shallow|deep" declares a child as
+// a history pseudo-state. A transition with "done: true" instead of an event
+// fires when its source state completes.
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/rluders/gofsm/fsm"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrInvalidDocument = errors.New("loader: invalid document")
+	ErrDuplicateState  = errors.New("loader: duplicate state")
+	ErrUnknownEvent    = errors.New("loader: unknown event")
+	ErrUnknownAction   = errors.New("loader: unknown action")
+	ErrUnknownGuard    = errors.New("loader: unknown guard")
+)
+
+// Error points at the part of the document that could not be loaded. File
+// is only set by LoadFile.
+type Error struct {
+	File   string
+	Line   int
+	Column int
+	Field  string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	pos := fmt.Sprintf("line %d, column %d", e.Line, e.Column)
+	if e.File != "" {
+		pos = fmt.Sprintf("%s:%d:%d", e.File, e.Line, e.Column)
+	}
+	if e.Field == "" {
+		return fmt.Sprintf("loader: %s: %v", pos, e.Err)
+	}
+	return fmt.Sprintf("loader: %s: field '%s': %v", pos, e.Field, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Document is a parsed machine document.
+type Document struct {
+	Definition   *fsm.Definition
+	InitialState string
+}
+
+// NewFSM creates the machine, starting in the document's initial state.
+func (d *Document) NewFSM(opts ...fsm.Option) (*fsm.FSM, error) {
+	return d.Definition.NewFSM(d.InitialState, opts...)
+}
+
+// Load parses a YAML or JSON document and creates the machine it describes.
+func Load(data []byte, registry *Registry, opts ...fsm.Option) (*fsm.FSM, error) {
+	doc, err := Parse(data, registry)
+	if err != nil {
+		return nil, err
+	}
+	return doc.NewFSM(opts...)
+}
+
+func LoadFile(path string, registry *Registry, opts ...fsm.Option) (*fsm.FSM, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := Parse(data, registry)
+	if err != nil {
+		var lerr *Error
+		if errors.As(err, &lerr) {
+			lerr.File = path
+		}
+		return nil, err
+	}
+	return doc.NewFSM(opts...)
+}
+
+// Parse reads a YAML or JSON document into a Definition.
+func Parse(data []byte, registry *Registry) (*Document, error) {
+	if registry == nil {
+		registry = NewRegistry()
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, syntaxError(err)
+	}
+	if len(root.Content) == 0 {
+		return nil, &Error{Line: 1, Column: 1, Err: fmt.Errorf("%w: empty document", ErrInvalidDocument)}
+	}
+
+	var spec document
+	if err := spec.decode(root.Content[0]); err != nil {
+		return nil, err
+	}
+	return spec.build(registry)
+}
+
+var lineRE = regexp.MustCompile(`line (\d+)`)
+
+func syntaxError(err error) error {
+	e := &Error{Err: fmt.Errorf("%w: %w", ErrInvalidDocument, err)}
+	if m := lineRE.FindStringSubmatch(err.Error()); m != nil {
+		e.Line, _ = strconv.Atoi(m[1])
+	}
+	return e
+}
+
+type document struct {
+	initial     field
+	events      []field
+	states      []*stateSpec
+	transitions []*transitionSpec
+}
+
+type stateSpec struct {
+	name     field
+	final    bool
+	parallel bool
+	history  field
+	timeout  *timeoutSpec
+	states   []*stateSpec
+	node     *yaml.Node
+	path     string
+}
+
+type timeoutSpec struct {
+	after field
+	event field
+}
+
+type transitionSpec struct {
+	from   field
+	event  field
+	done   bool
+	to     field
+	guard  field
+	action field
+	node   *yaml.Node
+	path   string
+}
+
+func (d *document) decode(n *yaml.Node) error {
+	return decodeMapping(n, "", map[string]fieldDecoder{
+		"initial": decodeString(&d.initial),
+		"events": func(n *yaml.Node, path string) error {
+			return decodeSequence(n, path, func(n *yaml.Node, path string) error {
+				var event field
+				if err := decodeString(&event)(n, path); err != nil {
+					return err
+				}
+				d.events = append(d.events, event)
+				return nil
+			})
+		},
+		"states":      decodeStates(&d.states),
+		"transitions": d.decodeTransitions,
+	})
+}
+
+func decodeStates(states *[]*stateSpec) fieldDecoder {
+	return func(n *yaml.Node, path string) error {
+		return decodeSequence(n, path, func(n *yaml.Node, path string) error {
+			s := &stateSpec{node: n, path: path}
+			err := decodeMapping(n, path, map[string]fieldDecoder{
+				"name":     decodeString(&s.name),
+				"final":    decodeBool(&s.final),
+				"parallel": decodeBool(&s.parallel),
+				"history":  decodeString(&s.history),
+				"timeout": func(n *yaml.Node, path string) error {
+					s.timeout = &timeoutSpec{}
+					return decodeMapping(n, path, map[string]fieldDecoder{
+						"after": decodeString(&s.timeout.after),
+						"event": decodeString(&s.timeout.event),
+					})
+				},
+				"states": decodeStates(&s.states),
+			})
+			if err != nil {
+				return err
+			}
+			*states = append(*states, s)
+			return nil
+		})
+	}
+}
+
+func (d *document) decodeTransitions(n *yaml.Node, path string) error {
+	return decodeSequence(n, path, func(n *yaml.Node, path string) error {
+		t := &transitionSpec{node: n, path: path}
+		err := decodeMapping(n, path, map[string]fieldDecoder{
+			"from":   decodeString(&t.from),
+			"event":  decodeString(&t.event),
+			"done":   decodeBool(&t.done),
+			"to":     decodeString(&t.to),
+			"guard":  decodeString(&t.guard),
+			"action": decodeString(&t.action),
+		})
+		if err != nil {
+			return err
+		}
+		d.transitions = append(d.transitions, t)
+		return nil
+	})
+}
+
+// build checks the references between the parts of the document and
+// declares them on a Definition.
+func (d *document) build(registry *Registry) (*Document, error) {
+	def := fsm.NewDefinition()
+	declared := make(map[string]bool)
+
+	events := make(map[string]bool, len(d.events))
+	for _, e := range d.events {
+		events[e.value] = true
+	}
+	checkEvent := func(f field) error {
+		if len(events) > 0 && !events[f.value] {
+			return f.errorf(fmt.Errorf("%w '%s'", ErrUnknownEvent, f.value))
+		}
+		return nil
+	}
+
+	var declare func(s *stateSpec, parent *stateSpec) error
+	declare = func(s *stateSpec, parent *stateSpec) error {
+		name := s.name.value
+		if name == "" {
+			return errorAt(s.node, join(s.path, "name"), fmt.Errorf("%w: state name is required", ErrInvalidDocument))
+		}
+		if declared[name] {
+			return s.name.errorf(fmt.Errorf("%w '%s'", ErrDuplicateState, name))
+		}
+		declared[name] = true
+
+		if s.history.value != "" {
+			return declareHistory(def, s, parent)
+		}
+
+		if impl, ok := registry.states[name]; ok {
+			def.Add(impl)
+		} else {
+			def.Declare(name)
+		}
+		if s.final {
+			def.Final(name)
+		}
+		if s.timeout != nil {
+			after, err := time.ParseDuration(s.timeout.after.value)
+			if err == nil && after <= 0 {
+				err = errors.New("timeout must be positive")
+			}
+			if err != nil {
+				node := s.timeout.after.node
+				if node == nil {
+					node = s.node
+				}
+				return errorAt(node, join(s.path, "timeout.after"), fmt.Errorf("%w: %w", ErrInvalidDocument, err))
+			}
+			if s.timeout.event.value == "" {
+				return errorAt(s.node, join(s.path, "timeout.event"), fmt.Errorf("%w: timeout event is required", ErrInvalidDocument))
+			}
+			if err := checkEvent(s.timeout.event); err != nil {
+				return err
+			}
+			def.Timeout(name, after, s.timeout.event.value)
+		}
+
+		for _, child := range s.states {
+			if err := declare(child, s); err != nil {
+				return err
+			}
+			if child.history.value != "" {
+				continue
+			}
+			if s.parallel {
+				def.Parallel(name, child.name.value)
+			} else {
+				def.Composite(name, child.name.value)
+			}
+		}
+		return nil
+	}
+	for _, s := range d.states {
+		if err := declare(s, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	checkState := func(f field, path string, node *yaml.Node) error {
+		if f.value == "" {
+			return errorAt(node, path, fmt.Errorf("%w: state is required", ErrInvalidDocument))
+		}
+		if !declared[f.value] {
+			return f.errorf(fmt.Errorf("%w '%s'", fsm.ErrUnknownState, f.value))
+		}
+		return nil
+	}
+
+	for _, t := range d.transitions {
+		if err := checkState(t.from, join(t.path, "from"), t.node); err != nil {
+			return nil, err
+		}
+		if err := checkState(t.to, join(t.path, "to"), t.node); err != nil {
+			return nil, err
+		}
+
+		b := def.From(t.from.value)
+		switch {
+		case t.done && t.event.value != "":
+			return nil, t.event.errorf(fmt.Errorf("%w: event and done are exclusive", ErrInvalidDocument))
+		case t.done:
+			b.OnDone()
+		case t.event.value == "":
+			return nil, errorAt(t.node, join(t.path, "event"), fmt.Errorf("%w: event is required", ErrInvalidDocument))
+		default:
+			if err := checkEvent(t.event); err != nil {
+				return nil, err
+			}
+			b.On(t.event.value)
+		}
+
+		if name := t.guard.value; name != "" {
+			guard, ok := registry.guards[name]
+			if !ok {
+				return nil, t.guard.errorf(fmt.Errorf("%w '%s'", ErrUnknownGuard, name))
+			}
+			b.GuardNamed(name, guard)
+		}
+		if name := t.action.value; name != "" {
+			action, ok := registry.actions[name]
+			if !ok {
+				return nil, t.action.errorf(fmt.Errorf("%w '%s'", ErrUnknownAction, name))
+			}
+			b.Do(action)
+		}
+		b.To(t.to.value)
+	}
+
+	if d.initial.node != nil {
+		if err := checkState(d.initial, "initial", d.initial.node); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Document{Definition: def, InitialState: d.initial.value}, nil
+}
+
+func declareHistory(def *fsm.Definition, s *stateSpec, parent *stateSpec) error {
+	if parent == nil || parent.parallel {
+		return s.history.errorf(fmt.Errorf("%w: history must be declared inside a composite state", ErrInvalidDocument))
+	}
+	if len(s.states) > 0 || s.final || s.parallel || s.timeout != nil {
+		return errorAt(s.node, s.path, fmt.Errorf("%w: history state '%s' cannot have other attributes", ErrInvalidDocument, s.name.value))
+	}
+
+	var kind fsm.HistoryKind
+	switch s.history.value {
+	case "shallow":
+		kind = fsm.ShallowHistory
+	case "deep":
+		kind = fsm.DeepHistory
+	default:
+		return s.history.errorf(fmt.Errorf("%w: history must be 'shallow' or 'deep'", ErrInvalidDocument))
+	}
+	def.History(s.name.value, parent.name.value, kind)
+	return nil
+}
@@ -0,0 +1,220 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rluders/gofsm/fsm"
+	"github.com/rluders/gofsm/storage/memory"
+)
+
+const scanYAML = `
+initial: pending
+events: [start_scan, all_jobs_completed, expire]
+states:
+  - name: pending
+    timeout: {after: 10m, event: expire}
+  - name: active
+    states:
+      - name: running
+      - name: finished
+        final: true
+      - name: active.history
+        history: deep
+  - name: completed
+    final: true
+  - name: expired
+    final: true
+transitions:
+  - {from: pending, event: start_scan, to: active, guard: hasTargets, action: startJobs}
+  - {from: pending, event: expire, to: expired}
+  - {from: running, event: all_jobs_completed, to: finished}
+  - {from: active, done: true, to: completed}
+`
+
+type recordingState struct {
+	name    string
+	entered int
+}
+
+func (s *recordingState) Name() string {
+	return s.name
+}
+
+func (s *recordingState) OnEnter(ctx context.Context, event fsm.Event) error {
+	s.entered++
+	return nil
+}
+
+func (s *recordingState) OnExit(ctx context.Context, event fsm.Event) error {
+	return nil
+}
+
+func newRegistry(started *int, running *recordingState) *Registry {
+	return NewRegistry().
+		Guard("hasTargets", func(ctx context.Context, entityID string, event fsm.Event) (bool, error) {
+			return event.Payload() != nil, nil
+		}).
+		Action("startJobs", func(ctx context.Context, entityID string, event fsm.Event) (any, error) {
+			*started++
+			return nil, nil
+		}).
+		State(running)
+}
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewMemoryStorage()
+	started := 0
+	running := &recordingState{name: "running"}
+
+	machine, err := Load([]byte(scanYAML), newRegistry(&started, running), fsm.WithStateStorage(storage), fsm.WithStrictValidation())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := machine.Init(ctx, "scan-1"); err != nil {
+		t.Fatalf("unexpected error on Init: %v", err)
+	}
+
+	err = machine.Trigger(ctx, "scan-1", fsm.NewBasicEvent("start_scan", nil))
+	if !errors.Is(err, fsm.ErrGuardRejected) {
+		t.Fatalf("expected ErrGuardRejected, got %v", err)
+	}
+
+	if err := machine.Trigger(ctx, "scan-1", fsm.NewBasicEvent("start_scan", []string{"10.0.0.1"})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if started != 1 || running.entered != 1 {
+		t.Errorf("expected action and OnEnter to run once, got %d and %d", started, running.entered)
+	}
+
+	if err := machine.Trigger(ctx, "scan-1", fsm.NewBasicEvent("all_jobs_completed", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state, _ := machine.CurrentState(ctx, "scan-1"); state != "completed" {
+		t.Errorf("expected state completed, got %s", state)
+	}
+}
+
+func TestParse_JSON(t *testing.T) {
+	doc, err := Parse([]byte(`{
+  "initial": "off",
+  "states": [{"name": "off"}, {"name": "on"}],
+  "transitions": [
+    {"from": "off", "event": "toggle", "to": "on"},
+    {"from": "on", "event": "toggle", "to": "off"}
+  ]
+}`), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if doc.InitialState != "off" {
+		t.Errorf("expected initial state off, got %s", doc.InitialState)
+	}
+	if rules := doc.Definition.Rules(); len(rules) != 2 {
+		t.Errorf("expected 2 rules, got %d", len(rules))
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		doc    string
+		err    error
+		line   int
+		column int
+		field  string
+	}{
+		{
+			name:   "unknown field",
+			doc:    "states:\n  - name: a\n    nmae: b\n",
+			err:    ErrInvalidDocument,
+			line:   3,
+			column: 5,
+			field:  "states[0].nmae",
+		},
+		{
+			name:   "unknown target",
+			doc:    "states:\n  - name: a\ntransitions:\n  - from: a\n    event: go\n    to: b\n",
+			err:    fsm.ErrUnknownState,
+			line:   6,
+			column: 9,
+			field:  "transitions[0].to",
+		},
+		{
+			name:   "unknown guard",
+			doc:    "states:\n  - name: a\ntransitions:\n  - {from: a, event: go, to: a, guard: nope}\n",
+			err:    ErrUnknownGuard,
+			line:   4,
+			column: 40,
+			field:  "transitions[0].guard",
+		},
+		{
+			name:   "duplicate state",
+			doc:    "states:\n  - name: a\n  - name: a\n",
+			err:    ErrDuplicateState,
+			line:   3,
+			column: 11,
+			field:  "states[1].name",
+		},
+		{
+			name:   "undeclared event",
+			doc:    "events: [go]\nstates:\n  - name: a\ntransitions:\n  - {from: a, event: stop, to: a}\n",
+			err:    ErrUnknownEvent,
+			line:   5,
+			column: 22,
+			field:  "transitions[0].event",
+		},
+		{
+			name:   "bad timeout",
+			doc:    "states:\n  - name: a\n    timeout:\n      after: soon\n      event: expire\n",
+			err:    ErrInvalidDocument,
+			line:   4,
+			column: 14,
+			field:  "states[0].timeout.after",
+		},
+		{
+			name:  "syntax error",
+			doc:   "initial: a\nstates: [\n",
+			err:   ErrInvalidDocument,
+			line:  2,
+			field: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.doc), nil)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			var lerr *Error
+			if !errors.As(err, &lerr) {
+				t.Fatalf("expected *Error, got %T", err)
+			}
+			if lerr.Line != tt.line || lerr.Column != tt.column || lerr.Field != tt.field {
+				t.Errorf("expected %d:%d %q, got %d:%d %q", tt.line, tt.column, tt.field, lerr.Line, lerr.Column, lerr.Field)
+			}
+		})
+	}
+}
+
+func TestLoadFile_ErrorHasPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "machine.yaml")
+	if err := os.WriteFile(path, []byte("initial: missing\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadFile(path, nil, fsm.WithStateStorage(memory.NewMemoryStorage()))
+	var lerr *Error
+	if !errors.As(err, &lerr) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	if lerr.File != path || lerr.Line != 1 {
+		t.Errorf("expected error at %s:1, got %v", path, err)
+	}
+}
@@ -0,0 +1,37 @@
+package loader
+
+import "github.com/rluders/gofsm/fsm"
+
+// Registry holds the Go code a machine document refers to by name.
+type Registry struct {
+	actions map[string]fsm.Action
+	guards  map[string]fsm.Guard
+	states  map[string]fsm.Lifecycle
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		actions: make(map[string]fsm.Action),
+		guards:  make(map[string]fsm.Guard),
+		states:  make(map[string]fsm.Lifecycle),
+	}
+}
+
+func (r *Registry) Action(name string, action fsm.Action) *Registry {
+	r.actions[name] = action
+	return r
+}
+
+func (r *Registry) Guard(name string, guard fsm.Guard) *Registry {
+	r.guards[name] = guard
+	return r
+}
+
+// State registers state implementations. They are attached to the document
+// states with the same name.
+func (r *Registry) State(states ...fsm.Lifecycle) *Registry {
+	for _, s := range states {
+		r.states[s.Name()] = s
+	}
+	return r
+}
@@ -0,0 +1,309 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TypedEvent is an Event whose name and payload have user-defined types.
+type TypedEvent[E ~string, P any] struct {
+	name    E
+	payload P
+}
+
+func NewTypedEvent[E ~string, P any](name E, payload P) TypedEvent[E, P] {
+	return TypedEvent[E, P]{name: name, payload: payload}
+}
+
+func (e TypedEvent[E, P]) Name() string {
+	return string(e.name)
+}
+
+func (e TypedEvent[E, P]) Payload() any {
+	return e.payload
+}
+
+func (e TypedEvent[E, P]) Event() E {
+	return e.name
+}
+
+func (e TypedEvent[E, P]) Data() P {
+	return e.payload
+}
+
+// typedEvent converts any Event into a TypedEvent. Events built elsewhere,
+// e.g. decoded from Kafka, are accepted when their payload is a P or nil.
+func typedEvent[E ~string, P any](event Event) (TypedEvent[E, P], error) {
+	if e, ok := event.(TypedEvent[E, P]); ok {
+		return e, nil
+	}
+
+	var payload P
+	if raw := event.Payload(); raw != nil {
+		p, ok := raw.(P)
+		if !ok {
+			return TypedEvent[E, P]{}, fmt.Errorf("fsm: event '%s' has payload %T, expected %T", event.Name(), raw, payload)
+		}
+		payload = p
+	}
+	return NewTypedEvent(E(event.Name()), payload), nil
+}
+
+type TypedGuard[E ~string, P any] func(ctx context.Context, entityID string, event TypedEvent[E, P]) (bool, error)
+
+type TypedAction[E ~string, P any] func(ctx context.Context, entityID string, event TypedEvent[E, P]) (any, error)
+
+type TypedHook[E ~string, P any] func(ctx context.Context, event TypedEvent[E, P]) error
+
+// MachineDefinition is the typed counterpart of Definition.
+type MachineDefinition[S ~string, E ~string, P any] struct {
+	def   *Definition
+	hooks map[S]*hookState[E, P]
+}
+
+func NewMachineDefinition[S ~string, E ~string, P any]() *MachineDefinition[S, E, P] {
+	return &MachineDefinition[S, E, P]{
+		def:   NewDefinition(),
+		hooks: make(map[S]*hookState[E, P]),
+	}
+}
+
+// Definition returns the untyped definition, e.g. for visualization.
+func (d *MachineDefinition[S, E, P]) Definition() *Definition {
+	return d.def
+}
+
+func (d *MachineDefinition[S, E, P]) OnEnter(state S, hook TypedHook[E, P]) *MachineDefinition[S, E, P] {
+	d.hook(state).onEnter = hook
+	return d
+}
+
+func (d *MachineDefinition[S, E, P]) OnExit(state S, hook TypedHook[E, P]) *MachineDefinition[S, E, P] {
+	d.hook(state).onExit = hook
+	return d
+}
+
+func (d *MachineDefinition[S, E, P]) From(state S) *MachineTransitionBuilder[S, E, P] {
+	return &MachineTransitionBuilder[S, E, P]{def: d, b: d.def.From(string(state))}
+}
+
+func (d *MachineDefinition[S, E, P]) Final(states ...S) *MachineDefinition[S, E, P] {
+	d.def.Final(names(states)...)
+	return d
+}
+
+func (d *MachineDefinition[S, E, P]) Composite(parent S, children ...S) *MachineDefinition[S, E, P] {
+	d.def.Composite(string(parent), names(children)...)
+	return d
+}
+
+func (d *MachineDefinition[S, E, P]) Parallel(state S, regions ...S) *MachineDefinition[S, E, P] {
+	d.def.Parallel(string(state), names(regions)...)
+	return d
+}
+
+func (d *MachineDefinition[S, E, P]) History(name, parent S, kind HistoryKind) *MachineDefinition[S, E, P] {
+	d.def.History(string(name), string(parent), kind)
+	return d
+}
+
+func (d *MachineDefinition[S, E, P]) Timeout(state S, after time.Duration, event E) *MachineDefinition[S, E, P] {
+	d.def.Timeout(string(state), after, string(event))
+	return d
+}
+
+func (d *MachineDefinition[S, E, P]) hook(state S) *hookState[E, P] {
+	h, ok := d.hooks[state]
+	if !ok {
+		h = &hookState[E, P]{name: string(state)}
+		d.hooks[state] = h
+		d.def.Add(h)
+	}
+	return h
+}
+
+type MachineTransitionBuilder[S ~string, E ~string, P any] struct {
+	def *MachineDefinition[S, E, P]
+	b   *TransitionBuilder
+}
+
+func (b *MachineTransitionBuilder[S, E, P]) On(event E) *MachineTransitionBuilder[S, E, P] {
+	b.b.On(string(event))
+	return b
+}
+
+func (b *MachineTransitionBuilder[S, E, P]) OnDone() *MachineTransitionBuilder[S, E, P] {
+	b.b.OnDone()
+	return b
+}
+
+func (b *MachineTransitionBuilder[S, E, P]) Guard(guard TypedGuard[E, P]) *MachineTransitionBuilder[S, E, P] {
+	b.b.Guard(func(ctx context.Context, entityID string, event Event) (bool, error) {
+		e, err := typedEvent[E, P](event)
+		if err != nil {
+			return false, err
+		}
+		return guard(ctx, entityID, e)
+	})
+	return b
+}
+
+func (b *MachineTransitionBuilder[S, E, P]) Do(action TypedAction[E, P]) *MachineTransitionBuilder[S, E, P] {
+	b.b.Do(func(ctx context.Context, entityID string, event Event) (any, error) {
+		e, err := typedEvent[E, P](event)
+		if err != nil {
+			return nil, err
+		}
+		return action(ctx, entityID, e)
+	})
+	return b
+}
+
+func (b *MachineTransitionBuilder[S, E, P]) To(state S) *MachineDefinition[S, E, P] {
+	b.b.To(string(state))
+	return b.def
+}
+
+type hookState[E ~string, P any] struct {
+	name    string
+	onEnter TypedHook[E, P]
+	onExit  TypedHook[E, P]
+}
+
+func (s *hookState[E, P]) Name() string {
+	return s.name
+}
+
+func (s *hookState[E, P]) OnEnter(ctx context.Context, event Event) error {
+	return runHook(ctx, s.onEnter, event)
+}
+
+func (s *hookState[E, P]) OnExit(ctx context.Context, event Event) error {
+	return runHook(ctx, s.onExit, event)
+}
+
+func runHook[E ~string, P any](ctx context.Context, hook TypedHook[E, P], event Event) error {
+	if hook == nil {
+		return nil
+	}
+	e, err := typedEvent[E, P](event)
+	if err != nil {
+		return err
+	}
+	return hook(ctx, e)
+}
+
+// Machine is a type-safe facade over FSM. It uses the same storages and
+// options, so typed and untyped code can drive the same entities.
+type Machine[S ~string, E ~string, P any] struct {
+	fsm *FSM
+}
+
+// MachineResult is a TriggerResult with the states involved in the
+// transition, see Machine.CurrentState.
+type MachineResult[S ~string] struct {
+	TriggerResult
+	FromState S
+	ToState   S
+}
+
+func NewMachine[S ~string, E ~string, P any](def *MachineDefinition[S, E, P], opts ...Option) (*Machine[S, E, P], error) {
+	f, err := NewFSM(def.def.States(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Machine[S, E, P]{fsm: f}, nil
+}
+
+// FSM returns the untyped machine.
+func (m *Machine[S, E, P]) FSM() *FSM {
+	return m.fsm
+}
+
+func (m *Machine[S, E, P]) Init(ctx context.Context, entityID string) error {
+	return m.fsm.Init(ctx, entityID)
+}
+
+func (m *Machine[S, E, P]) Trigger(ctx context.Context, entityID string, event E, payload P) (MachineResult[S], error) {
+	result, err := m.fsm.TriggerWithResult(ctx, entityID, NewTypedEvent(event, payload))
+	return MachineResult[S]{
+		TriggerResult: result,
+		FromState:     S(m.fsm.innermost(m.fsm.parseConfiguration(result.From))),
+		ToState:       S(m.fsm.innermost(m.fsm.parseConfiguration(result.To))),
+	}, err
+}
+
+func (m *Machine[S, E, P]) CanTrigger(ctx context.Context, entityID string, event E, payload P) (bool, error) {
+	return m.fsm.CanTrigger(ctx, entityID, NewTypedEvent(event, payload))
+}
+
+// AvailableEvents lists the accepted events, see FSM.AvailableEvents. Guards
+// see the zero payload.
+func (m *Machine[S, E, P]) AvailableEvents(ctx context.Context, entityID string) ([]E, error) {
+	names, err := m.fsm.AvailableEvents(ctx, entityID)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]E, len(names))
+	for i, name := range names {
+		events[i] = E(name)
+	}
+	return events, nil
+}
+
+// CurrentState returns the innermost state that contains every active leaf:
+// the leaf itself, or the parallel state whose regions are active.
+func (m *Machine[S, E, P]) CurrentState(ctx context.Context, entityID string) (S, error) {
+	value, err := m.fsm.CurrentState(ctx, entityID)
+	if err != nil {
+		return "", err
+	}
+	return S(m.fsm.innermost(m.fsm.parseConfiguration(value))), nil
+}
+
+// ActiveStates returns every active leaf state.
+func (m *Machine[S, E, P]) ActiveStates(ctx context.Context, entityID string) ([]S, error) {
+	value, err := m.fsm.CurrentState(ctx, entityID)
+	if err != nil {
+		return nil, err
+	}
+	config := m.fsm.parseConfiguration(value)
+	states := make([]S, len(config))
+	for i, leaf := range config {
+		states[i] = S(leaf)
+	}
+	return states, nil
+}
+
+// innermost returns the deepest state containing every leaf, or the leaf
+// when there is only one.
+func (f *FSM) innermost(config configuration) string {
+	if len(config) == 0 {
+		return ""
+	}
+	if len(config) == 1 {
+		return config[0]
+	}
+	for _, s := range f.lineage(f.parentOf(config[0])) {
+		contains := true
+		for _, leaf := range config[1:] {
+			if !f.isAncestor(s, leaf) {
+				contains = false
+				break
+			}
+		}
+		if contains {
+			return s
+		}
+	}
+	return ""
+}
+
+func names[S ~string](states []S) []string {
+	out := make([]string, len(states))
+	for i, s := range states {
+		out[i] = string(s)
+	}
+	return out
+}
@@ -0,0 +1,150 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type orderState string
+
+type orderEvent string
+
+type orderPayload struct {
+	Amount int
+}
+
+const (
+	orderPending orderState = "pending"
+	orderPaid    orderState = "paid"
+	orderShipped orderState = "shipped"
+
+	orderPay  orderEvent = "pay"
+	orderShip orderEvent = "ship"
+)
+
+func newOrderMachine(t *testing.T, storage StateStorage) (*Machine[orderState, orderEvent, orderPayload], *int) {
+	t.Helper()
+
+	var charged int
+	def := NewMachineDefinition[orderState, orderEvent, orderPayload]().
+		OnEnter(orderPaid, func(ctx context.Context, event TypedEvent[orderEvent, orderPayload]) error {
+			charged += event.Data().Amount
+			return nil
+		}).
+		From(orderPending).On(orderPay).
+		Guard(func(ctx context.Context, entityID string, event TypedEvent[orderEvent, orderPayload]) (bool, error) {
+			return event.Data().Amount > 0, nil
+		}).
+		Do(func(ctx context.Context, entityID string, event TypedEvent[orderEvent, orderPayload]) (any, error) {
+			return event.Data().Amount * 2, nil
+		}).
+		To(orderPaid).
+		From(orderPaid).On(orderShip).To(orderShipped).
+		Final(orderShipped)
+
+	m, err := NewMachine(def, WithStateStorage(storage), WithInitialState(string(orderPending)), WithLogger(&MockLogger{}))
+	if err != nil {
+		t.Fatalf("unexpected error creating machine: %v", err)
+	}
+	return m, &charged
+}
+
+func TestMachine_Trigger(t *testing.T) {
+	ctx := context.Background()
+	entityID := "order-1"
+	m, charged := newOrderMachine(t, NewFakeStorage())
+
+	if err := m.Init(ctx, entityID); err != nil {
+		t.Fatalf("unexpected error on Init: %v", err)
+	}
+
+	_, err := m.Trigger(ctx, entityID, orderPay, orderPayload{Amount: 0})
+	if !errors.Is(err, ErrGuardRejected) {
+		t.Fatalf("expected ErrGuardRejected, got %v", err)
+	}
+
+	result, err := m.Trigger(ctx, entityID, orderPay, orderPayload{Amount: 21})
+	if err != nil {
+		t.Fatalf("unexpected error on Trigger: %v", err)
+	}
+	if result.FromState != orderPending || result.ToState != orderPaid {
+		t.Errorf("expected pending → paid, got %s → %s", result.FromState, result.ToState)
+	}
+	if result.Output != 42 {
+		t.Errorf("expected output 42, got %v", result.Output)
+	}
+	if *charged != 21 {
+		t.Errorf("expected OnEnter to see amount 21, got %d", *charged)
+	}
+
+	events, err := m.AvailableEvents(ctx, entityID)
+	if err != nil {
+		t.Fatalf("unexpected error on AvailableEvents: %v", err)
+	}
+	if len(events) != 1 || events[0] != orderShip {
+		t.Errorf("expected [%s], got %v", orderShip, events)
+	}
+
+	state, err := m.CurrentState(ctx, entityID)
+	if err != nil {
+		t.Fatalf("unexpected error on CurrentState: %v", err)
+	}
+	if state != orderPaid {
+		t.Errorf("expected state %s, got %s", orderPaid, state)
+	}
+}
+
+func TestMachine_UntypedEvents(t *testing.T) {
+	ctx := context.Background()
+	entityID := "order-2"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, string(orderPending))
+	m, _ := newOrderMachine(t, storage)
+
+	err := m.FSM().Trigger(ctx, entityID, NewBasicEvent(string(orderPay), "not a payload"))
+	if err == nil {
+		t.Fatal("expected error for mismatched payload type")
+	}
+
+	if err := m.FSM().Trigger(ctx, entityID, NewBasicEvent(string(orderPay), orderPayload{Amount: 5})); err != nil {
+		t.Fatalf("unexpected error on Trigger: %v", err)
+	}
+	if state, _ := storage.GetState(ctx, entityID); state != string(orderPaid) {
+		t.Errorf("expected state %s, got %s", orderPaid, state)
+	}
+}
+
+func TestMachine_CurrentStateParallel(t *testing.T) {
+	ctx := context.Background()
+	entityID := "scan-1"
+
+	def := NewMachineDefinition[orderState, orderEvent, any]().
+		Parallel("scan", "jobs", "notify").
+		Composite("jobs", "running").
+		Composite("notify", "unsent")
+
+	m, err := NewMachine(def, WithStateStorage(NewFakeStorage()), WithInitialState("scan"), WithLogger(&MockLogger{}))
+	if err != nil {
+		t.Fatalf("unexpected error creating machine: %v", err)
+	}
+	if err := m.Init(ctx, entityID); err != nil {
+		t.Fatalf("unexpected error on Init: %v", err)
+	}
+
+	state, err := m.CurrentState(ctx, entityID)
+	if err != nil {
+		t.Fatalf("unexpected error on CurrentState: %v", err)
+	}
+	if state != "scan" {
+		t.Errorf("expected state scan, got %s", state)
+	}
+
+	leaves, err := m.ActiveStates(ctx, entityID)
+	if err != nil {
+		t.Fatalf("unexpected error on ActiveStates: %v", err)
+	}
+	if len(leaves) != 2 || leaves[0] != "running" || leaves[1] != "unsent" {
+		t.Errorf("expected [running unsent], got %v", leaves)
+	}
+}
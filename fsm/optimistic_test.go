@@ -0,0 +1,155 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type FakeVersionedStorage struct {
+	*FakeStorage
+	versions map[string]int64
+}
+
+func NewFakeVersionedStorage() *FakeVersionedStorage {
+	return &FakeVersionedStorage{FakeStorage: NewFakeStorage(), versions: make(map[string]int64)}
+}
+
+func (s *FakeVersionedStorage) SetState(ctx context.Context, entityID, state string) error {
+	s.versions[entityID]++
+	return s.FakeStorage.SetState(ctx, entityID, state)
+}
+
+func (s *FakeVersionedStorage) GetStateVersion(ctx context.Context, entityID string) (string, int64, error) {
+	state, err := s.GetState(ctx, entityID)
+	return state, s.versions[entityID], err
+}
+
+func (s *FakeVersionedStorage) CompareAndSetState(ctx context.Context, entityID, state string, version int64) (int64, error) {
+	if s.versions[entityID] != version {
+		return 0, fmt.Errorf("%w: '%s'", ErrVersionConflict, entityID)
+	}
+	if err := s.SetState(ctx, entityID, state); err != nil {
+		return 0, err
+	}
+	return s.versions[entityID], nil
+}
+
+func newOptimisticFSM(t *testing.T, storage *FakeVersionedStorage, action Action, retries int) *FSM {
+	t.Helper()
+	def := NewDefinition().
+		From("pending").On("approve").Do(action).To("approved").
+		From("approved").On("approve").To("approved")
+
+	fsm, err := NewFSM(def.States(),
+		WithOptimisticConcurrency(storage, retries),
+		WithLogger(&MockLogger{}),
+		WithInitialState("pending"),
+		WithAutoInit(),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error creating FSM: %v", err)
+	}
+	return fsm
+}
+
+func TestFSM_OptimisticConcurrencyRetry(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-cas"
+	storage := NewFakeVersionedStorage()
+
+	calls := 0
+	action := func(ctx context.Context, id string, event Event) (any, error) {
+		calls++
+		if calls == 1 {
+			// Another writer changes the entity while the event is handled.
+			return nil, storage.SetState(ctx, id, "pending")
+		}
+		return nil, nil
+	}
+	fsm := newOptimisticFSM(t, storage, action, 3)
+
+	result, err := fsm.TriggerWithResult(ctx, entityID, NewBasicEvent("approve", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Conflicts != 1 || calls != 2 {
+		t.Errorf("expected one retry, got %d conflicts and %d calls", result.Conflicts, calls)
+	}
+	if state, version, _ := storage.GetStateVersion(ctx, entityID); state != "approved" || version != 3 {
+		t.Errorf("unexpected state %s at version %d", state, version)
+	}
+}
+
+func TestFSM_OptimisticConcurrencyExhausted(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-cas-busy"
+	storage := NewFakeVersionedStorage()
+	storage.SetState(ctx, entityID, "pending")
+
+	calls := 0
+	action := func(ctx context.Context, id string, event Event) (any, error) {
+		calls++
+		return nil, storage.SetState(ctx, id, "pending")
+	}
+	fsm := newOptimisticFSM(t, storage, action, 2)
+
+	result, err := fsm.TriggerWithResult(ctx, entityID, NewBasicEvent("approve", nil))
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	if result.Conflicts != 2 || calls != 3 {
+		t.Errorf("expected 2 retries, got %d conflicts and %d calls", result.Conflicts, calls)
+	}
+	if state, _ := storage.GetState(ctx, entityID); state != "pending" {
+		t.Errorf("expected entity to stay pending, got %s", state)
+	}
+}
+
+// racingStorage lets another writer store a state right after every read.
+type racingStorage struct {
+	*FakeVersionedStorage
+	state string
+}
+
+func (s *racingStorage) GetStateVersion(ctx context.Context, entityID string) (string, int64, error) {
+	state, version, err := s.FakeVersionedStorage.GetStateVersion(ctx, entityID)
+	if err == nil {
+		s.SetState(ctx, entityID, s.state)
+	}
+	return state, version, err
+}
+
+func TestFSM_RebuildVersionConflict(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-cas-rebuild"
+	storage := &racingStorage{FakeVersionedStorage: NewFakeVersionedStorage(), state: "approved"}
+	history := &FakeHistoryStore{}
+	history.Append(ctx, TransitionRecord{EntityID: entityID, Event: InitEvent, To: "pending"})
+	storage.FakeVersionedStorage.SetState(ctx, entityID, "lost")
+
+	def := NewDefinition().
+		From("pending").On("approve").To("approved").
+		Final("approved")
+	fsm, err := NewFSM(def.States(),
+		WithOptimisticConcurrency(storage, 3),
+		WithLogger(&MockLogger{}),
+		WithInitialState("pending"),
+		WithHistoryStore(history),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error creating FSM: %v", err)
+	}
+
+	result, err := fsm.Rebuild(ctx, entityID)
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	if result.Rebuilt {
+		t.Error("expected entity not to be rebuilt")
+	}
+	if state, _ := storage.GetState(ctx, entityID); state != "approved" {
+		t.Errorf("expected the concurrent write to be kept, got %s", state)
+	}
+}
@@ -20,6 +20,12 @@ func WithTransitionHook(hook TransitionHook) Option {
 	}
 }
 
+func WithCompletionHook(hook CompletionHook) Option {
+	return func(f *FSM) {
+		f.completionHook = hook
+	}
+}
+
 func WithAutoLock(storage LockableStorage, cfg LockRetryConfig, onFail LockFailureHandler) Option {
 	return func(f *FSM) {
 		f.lockableStorage = storage
@@ -31,3 +37,71 @@ func WithAutoLock(storage LockableStorage, cfg LockRetryConfig, onFail LockFailu
 		}
 	}
 }
+
+// WithOptimisticConcurrency replaces locking with compare-and-swap writes.
+// When another writer changed the entity since it was read, the whole event
+// is handled again from the new state, up to maxRetries times, so hooks and
+// actions must tolerate being run more than once.
+func WithOptimisticConcurrency(storage VersionedStorage, maxRetries int) Option {
+	return func(f *FSM) {
+		f.versioned = storage
+		f.casRetries = maxRetries
+
+		if f.storage == nil {
+			f.storage = storage
+		}
+	}
+}
+
+func WithInitialState(name string) Option {
+	return func(f *FSM) {
+		f.initialState = name
+	}
+}
+
+// WithAutoInit initializes unknown entities in the initial state when they
+// receive their first event.
+func WithAutoInit() Option {
+	return func(f *FSM) {
+		f.autoInit = true
+	}
+}
+
+// WithEntityLoader loads the domain object of the entity handled by
+// Trigger when the caller did not pass one with WithEntity.
+func WithEntityLoader(loader EntityLoader) Option {
+	return func(f *FSM) {
+		f.entityLoader = loader
+	}
+}
+
+// WithTimerStore enables state timeouts. Without a TimerStore, timeouts
+// declared on the definition are ignored.
+func WithTimerStore(store TimerStore) Option {
+	return func(f *FSM) {
+		f.timers = store
+	}
+}
+
+// WithHistoryStore records every transition in store, see FSM.History.
+func WithHistoryStore(store HistoryStore) Option {
+	return func(f *FSM) {
+		f.history = store
+	}
+}
+
+// WithMaxChainDepth limits how many internal events, raised with Raise or
+// by completed states, a single Trigger call processes.
+func WithMaxChainDepth(depth int) Option {
+	return func(f *FSM) {
+		f.maxChainDepth = depth
+	}
+}
+
+// WithStrictValidation makes NewFSM fail with a ValidationError when
+// Validate finds any problem. Otherwise problems are only logged.
+func WithStrictValidation() Option {
+	return func(f *FSM) {
+		f.strict = true
+	}
+}
@@ -0,0 +1,177 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func newParallelDefinition(calls *[]string) *Definition {
+	return NewDefinition().
+		Add(
+			&LifecycleRecorder{name: "scan", calls: calls},
+			&LifecycleRecorder{name: "running", calls: calls},
+			&LifecycleRecorder{name: "unsent", calls: calls},
+		).
+		Parallel("scan", "jobs", "notify").
+		Composite("jobs", "running", "jobs_done").
+		Composite("notify", "unsent", "sent").
+		From("pending").On("start").To("scan").
+		From("running").On("finish").To("jobs_done").
+		From("unsent").On("notify").To("sent").
+		From("unsent").On("finish").To("unsent").
+		From("scan").On("cancel").To("cancelled").
+		From("scan").OnDone().To("completed").
+		Final("jobs_done", "sent", "completed")
+}
+
+func TestFSM_Parallel(t *testing.T) {
+	cases := []struct {
+		name         string
+		initialState string
+		event        string
+		expectState  string
+		expectCalls  []string
+	}{
+		{
+			name:         "entering parallel state enters every region",
+			initialState: "pending",
+			event:        "start",
+			expectState:  "scan/jobs/running,scan/notify/unsent",
+			expectCalls:  []string{"enter:scan", "enter:running", "enter:unsent"},
+		},
+		{
+			name:         "event is dispatched to every region",
+			initialState: "scan/jobs/running,scan/notify/unsent",
+			event:        "finish",
+			expectState:  "scan/jobs/jobs_done,scan/notify/unsent",
+			expectCalls:  []string{"exit:running"},
+		},
+		{
+			name:         "parent transition exits all regions",
+			initialState: "scan/jobs/running,scan/notify/unsent",
+			event:        "cancel",
+			expectState:  "cancelled",
+			expectCalls:  []string{"exit:unsent", "exit:running", "exit:scan"},
+		},
+		{
+			name:         "join fires when all regions are final",
+			initialState: "scan/jobs/jobs_done,scan/notify/unsent",
+			event:        "notify",
+			expectState:  "completed",
+			expectCalls:  []string{"exit:unsent", "exit:scan"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			entityID := "entity-parallel"
+			storage := NewFakeStorage()
+			storage.SetState(ctx, entityID, tt.initialState)
+
+			var calls []string
+			fsm, err := NewFSM(newParallelDefinition(&calls).States(),
+				WithStateStorage(storage),
+				WithLogger(&MockLogger{}),
+			)
+			if err != nil {
+				t.Fatalf("failed to create FSM: %v", err)
+			}
+
+			if err := fsm.Trigger(ctx, entityID, NewBasicEvent(tt.event, nil)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			state, _ := storage.GetState(ctx, entityID)
+			if state != tt.expectState {
+				t.Errorf("expected state %q, got %q", tt.expectState, state)
+			}
+
+			if len(calls) != len(tt.expectCalls) {
+				t.Fatalf("expected calls %v, got %v", tt.expectCalls, calls)
+			}
+			for i := range calls {
+				if calls[i] != tt.expectCalls[i] {
+					t.Fatalf("expected calls %v, got %v", tt.expectCalls, calls)
+				}
+			}
+		})
+	}
+}
+
+func TestFSM_Parallel_JoinHooks(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-parallel-join"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "scan/jobs/jobs_done,scan/notify/unsent")
+
+	var transitions []string
+	hook := func(ctx context.Context, id, from, to string, event Event) {
+		transitions = append(transitions, event.Name()+":"+to)
+	}
+	completed := ""
+	onComplete := func(ctx context.Context, id, state string, event Event) {
+		completed = state
+	}
+
+	var calls []string
+	fsm, err := NewFSM(newParallelDefinition(&calls).States(),
+		WithStateStorage(storage),
+		WithLogger(&MockLogger{}),
+		WithTransitionHook(hook),
+		WithCompletionHook(onComplete),
+	)
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+
+	result, err := fsm.TriggerWithResult(ctx, entityID, NewBasicEvent("notify", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.To != "completed" || !result.Changed {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	expected := []string{"notify:scan/jobs/jobs_done,scan/notify/sent", DoneEvent("scan") + ":completed"}
+	if len(transitions) != len(expected) || transitions[0] != expected[0] || transitions[1] != expected[1] {
+		t.Errorf("expected transitions %v, got %v", expected, transitions)
+	}
+	if completed != "completed" {
+		t.Errorf("expected completion in %q, got %q", "completed", completed)
+	}
+}
+
+func TestFSM_FlatStateNamesWithRegionSeparator(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-comma"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "waiting,retrying")
+
+	def := NewDefinition().
+		From("waiting,retrying").On("give_up").To("failed")
+
+	fsm, err := NewFSM(def.States(), WithStateStorage(storage), WithLogger(&MockLogger{}))
+	if err != nil {
+		t.Fatalf("unexpected error creating FSM: %v", err)
+	}
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("give_up", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state, _ := storage.GetState(ctx, entityID); state != "failed" {
+		t.Errorf("expected state 'failed', got %s", state)
+	}
+}
+
+func TestNewFSM_RejectsSeparatorInParallelMachine(t *testing.T) {
+	def := NewDefinition().
+		Parallel("scan", "jobs", "billing").
+		Composite("jobs", "a,b").
+		Composite("billing", "pending")
+
+	_, err := NewFSM(def.States(), WithStateStorage(NewFakeStorage()), WithLogger(&MockLogger{}))
+	if !errors.Is(err, ErrInvalidDefinition) {
+		t.Fatalf("expected ErrInvalidDefinition, got %v", err)
+	}
+}
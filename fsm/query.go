@@ -0,0 +1,109 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CanTrigger reports whether Trigger would accept the event for the entity
+// in its stored state. Guards are evaluated, but no hook, action or storage
+// write is run. Unknown entities are evaluated in the initial state when
+// auto-init is enabled.
+func (f *FSM) CanTrigger(ctx context.Context, entityID string, event Event) (bool, error) {
+	ctx, config, err := f.peek(ctx, entityID)
+	if err != nil {
+		return false, err
+	}
+	return f.accepts(ctx, entityID, config, event)
+}
+
+// AvailableEvents lists the declared events the entity currently accepts,
+// in declaration order. Guards see events without a payload. Events handled
+// only by a State's own HandleEvent cannot be listed, and completion events
+// are left out since they are raised internally.
+func (f *FSM) AvailableEvents(ctx context.Context, entityID string) ([]string, error) {
+	ctx, config, err := f.peek(ctx, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	isActive := f.activeStates(config)
+	active := make([]string, 0, len(isActive))
+	for s := range isActive {
+		active = append(active, s)
+	}
+	f.sortStates(active)
+
+	seen := make(map[string]bool)
+	var events []string
+	for _, r := range f.rules(active) {
+		if !isActive[r.From] || seen[r.Event] || strings.HasPrefix(r.Event, DoneEvent("")) {
+			continue
+		}
+		seen[r.Event] = true
+
+		ok, err := f.accepts(ctx, entityID, config, NewBasicEvent(r.Event, nil))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			events = append(events, r.Event)
+		}
+	}
+	return events, nil
+}
+
+// peek reads the configuration of an entity without changing anything. The
+// returned context carries the entity and its extended state, whose changes
+// are never saved.
+func (f *FSM) peek(ctx context.Context, entityID string) (context.Context, configuration, error) {
+	ctx = withEntityID(ctx, entityID)
+	current, x, _, err := f.getState(ctx, entityID)
+	if x != nil {
+		ctx = withExtendedState(ctx, x)
+	}
+	initialize := errors.Is(err, ErrEntityNotFound) && f.autoInit
+	if err != nil && !initialize {
+		return ctx, nil, err
+	}
+	if ctx, err = f.loadEntity(ctx, entityID); err != nil {
+		return ctx, nil, err
+	}
+	if initialize {
+		return ctx, f.apply(nil, plannedTransition{entries: f.entrySet("", []string{f.initialState})}), nil
+	}
+
+	config := f.parseConfiguration(current)
+	for _, leaf := range config {
+		if _, ok := f.states[leaf]; !ok {
+			return ctx, nil, fmt.Errorf("%w: current state '%s'", ErrUnknownState, current)
+		}
+	}
+	return ctx, config, nil
+}
+
+// accepts reports whether any active region has an enabled transition for
+// the event.
+func (f *FSM) accepts(ctx context.Context, entityID string, config configuration, event Event) (bool, error) {
+	if f.isCompleted(config) {
+		return false, nil
+	}
+	for _, leaf := range config {
+		transition, _, err := f.handle(ctx, entityID, leaf, event)
+		switch {
+		case errors.Is(err, ErrGuardRejected), errors.Is(err, ErrNoTransition):
+			continue
+		case err != nil:
+			return false, err
+		}
+		if next := transition.NextState; next != "" {
+			if _, ok := f.states[next]; !ok {
+				return false, fmt.Errorf("%w: next state '%s'", ErrUnknownState, next)
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
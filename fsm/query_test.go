@@ -0,0 +1,106 @@
+package fsm
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFSM_CanTrigger(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-query"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "pending")
+
+	var hooked, acted int
+	def := NewDefinition().
+		From("pending").On("start_scan").
+		Guard(func(ctx context.Context, id string, event Event) (bool, error) {
+			return event.Payload() != nil, nil
+		}).
+		Do(func(ctx context.Context, id string, event Event) (any, error) {
+			acted++
+			return nil, nil
+		}).
+		To("running").
+		From("running").On("all_jobs_completed").To("completed").
+		Final("completed")
+
+	fsm, err := NewFSM(def.States(),
+		WithStateStorage(storage),
+		WithLogger(&MockLogger{}),
+		WithTransitionHook(func(ctx context.Context, id, from, to string, event Event) {
+			hooked++
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error creating FSM: %v", err)
+	}
+
+	tests := []struct {
+		event Event
+		want  bool
+	}{
+		{NewBasicEvent("start_scan", "targets"), true},
+		{NewBasicEvent("start_scan", nil), false},
+		{NewBasicEvent("all_jobs_completed", nil), false},
+		{NewBasicEvent("unknown", nil), false},
+	}
+	for _, tt := range tests {
+		ok, err := fsm.CanTrigger(ctx, entityID, tt.event)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", tt.event.Name(), err)
+		}
+		if ok != tt.want {
+			t.Errorf("CanTrigger(%s, %v) = %v, want %v", tt.event.Name(), tt.event.Payload(), ok, tt.want)
+		}
+	}
+
+	if state, _ := storage.GetState(ctx, entityID); state != "pending" || hooked != 0 || acted != 0 {
+		t.Errorf("expected no side effects, got state %s, %d hooks, %d actions", state, hooked, acted)
+	}
+
+	storage.SetState(ctx, entityID, "completed")
+	if ok, err := fsm.CanTrigger(ctx, entityID, NewBasicEvent("all_jobs_completed", nil)); ok || err != nil {
+		t.Errorf("expected completed entity to accept nothing, got %v, %v", ok, err)
+	}
+}
+
+func TestFSM_AvailableEvents(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-available"
+
+	reject := func(ctx context.Context, id string, event Event) (bool, error) {
+		return false, nil
+	}
+
+	def := NewDefinition().
+		Composite("active", "idle", "running").
+		From("idle").On("start").To("running").
+		From("idle").On("configure").Guard(reject).To("idle").
+		From("running").On("pause").To("idle").
+		From("active").On("cancel").To("cancelled").
+		From("active").OnDone().To("cancelled").
+		Final("cancelled")
+
+	fsm, err := NewFSM(def.States(),
+		WithStateStorage(NewFakeStorage()),
+		WithLogger(&MockLogger{}),
+		WithInitialState("active"),
+		WithAutoInit(),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error creating FSM: %v", err)
+	}
+
+	events, err := fsm.AvailableEvents(ctx, entityID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 2 || events[0] != "start" || events[1] != "cancel" {
+		t.Errorf("expected [start cancel], got %v", events)
+	}
+
+	if _, err := fsm.CurrentState(ctx, entityID); err == nil {
+		t.Error("expected AvailableEvents not to initialize the entity")
+	}
+}
@@ -0,0 +1,178 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type MutexLockStorage struct {
+	StateStorage
+	mu sync.Mutex
+}
+
+func (s *MutexLockStorage) Lock(ctx context.Context, entityID string) (func(), error) {
+	if !s.mu.TryLock() {
+		return nil, errors.New("already locked")
+	}
+	return s.mu.Unlock, nil
+}
+
+type RaisingState struct {
+	name  string
+	raise string
+}
+
+func (s *RaisingState) Name() string {
+	return s.name
+}
+
+func (s *RaisingState) OnEnter(ctx context.Context, event Event) error {
+	return Raise(ctx, NewBasicEvent(s.raise, nil))
+}
+
+func (s *RaisingState) OnExit(ctx context.Context, event Event) error {
+	return nil
+}
+
+func TestFSM_Raise(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-raise"
+	storage := &MutexLockStorage{StateStorage: NewFakeStorage()}
+	storage.SetState(ctx, entityID, "pending")
+
+	var events []string
+	hook := func(ctx context.Context, id, from, to string, event Event) {
+		events = append(events, event.Name())
+	}
+
+	def := NewDefinition().
+		Add(&RaisingState{name: "running", raise: "all_jobs_completed"}).
+		From("pending").On("start_scan").To("running").
+		From("running").On("all_jobs_completed").To("completed")
+
+	fsm, err := NewFSM(def.States(),
+		WithLogger(&MockLogger{}),
+		WithAutoLock(storage, LockRetryConfig{}, nil),
+		WithTransitionHook(hook),
+	)
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fsm.Trigger(ctx, entityID, NewBasicEvent("start_scan", nil))
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("trigger did not return")
+	}
+
+	state, _ := storage.GetState(ctx, entityID)
+	if state != "completed" {
+		t.Errorf("expected state %q, got %q", "completed", state)
+	}
+	if len(events) != 2 || events[0] != "start_scan" || events[1] != "all_jobs_completed" {
+		t.Errorf("unexpected hook events: %v", events)
+	}
+}
+
+func TestFSM_Raise_MaxChainDepth(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-raise-loop"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "ping")
+
+	def := NewDefinition().
+		Add(
+			&RaisingState{name: "ping", raise: "bounce"},
+			&RaisingState{name: "pong", raise: "bounce"},
+		).
+		From("ping").On("bounce").To("pong").
+		From("pong").On("bounce").To("ping")
+
+	fsm, err := NewFSM(def.States(),
+		WithStateStorage(storage),
+		WithLogger(&MockLogger{}),
+		WithMaxChainDepth(5),
+	)
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+
+	err = fsm.Trigger(ctx, entityID, NewBasicEvent("bounce", nil))
+	if !errors.Is(err, ErrChainDepthExceeded) {
+		t.Fatalf("expected ErrChainDepthExceeded, got %v", err)
+	}
+
+	state, _ := storage.GetState(ctx, entityID)
+	if state != "ping" {
+		t.Errorf("expected state %q, got %q", "ping", state)
+	}
+}
+
+func TestRaise_OutsideTrigger(t *testing.T) {
+	if err := Raise(context.Background(), NewBasicEvent("e", nil)); err == nil {
+		t.Error("expected error when raising outside of Trigger")
+	}
+}
+
+func TestFSM_Raise_InInitialState(t *testing.T) {
+	newFSM := func(storage StateStorage, history HistoryStore, opts ...Option) *FSM {
+		def := NewDefinition().
+			Add(&RaisingState{name: "created", raise: "validate"}).
+			From("created").On("validate").To("validated")
+
+		opts = append([]Option{
+			WithStateStorage(storage),
+			WithLogger(&MockLogger{}),
+			WithInitialState("created"),
+			WithHistoryStore(history),
+		}, opts...)
+		fsm, err := NewFSM(def.States(), opts...)
+		if err != nil {
+			t.Fatalf("failed to create FSM: %v", err)
+		}
+		return fsm
+	}
+
+	t.Run("Init", func(t *testing.T) {
+		ctx := context.Background()
+		storage := NewFakeStorage()
+		history := &FakeHistoryStore{}
+		fsm := newFSM(storage, history)
+
+		if err := fsm.Init(ctx, "entity-raise-init"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if state, _ := storage.GetState(ctx, "entity-raise-init"); state != "validated" {
+			t.Errorf("expected state validated, got %s", state)
+		}
+		if len(history.records) != 2 || history.records[1].From != "created" || history.records[1].To != "validated" {
+			t.Errorf("expected the raised transition to be recorded, got %+v", history.records)
+		}
+	})
+
+	t.Run("auto-init", func(t *testing.T) {
+		ctx := context.Background()
+		storage := NewFakeStorage()
+		lock := &MutexLockStorage{StateStorage: storage}
+		fsm := newFSM(storage, &FakeHistoryStore{}, WithAutoInit(), WithAutoLock(lock, LockRetryConfig{}, nil))
+
+		err := fsm.Trigger(ctx, "entity-raise-auto", NewBasicEvent("validate", nil))
+		if !errors.Is(err, ErrNoTransition) {
+			t.Fatalf("expected the event to find the entity validated, got %v", err)
+		}
+		if state, _ := storage.GetState(ctx, "entity-raise-auto"); state != "validated" {
+			t.Errorf("expected state validated, got %s", state)
+		}
+	})
+}
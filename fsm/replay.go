@@ -0,0 +1,200 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type ReplayMode int
+
+const (
+	// ReplayVerify only compares the replayed state with StateStorage.
+	ReplayVerify ReplayMode = iota
+	// ReplayRebuild also writes the replayed state when it differs.
+	ReplayRebuild
+)
+
+// Divergence is a recorded transition that the definition no longer
+// reproduces: replaying its event failed, or led to another state.
+type Divergence struct {
+	Index    int
+	Record   TransitionRecord
+	Replayed string
+	Err      error
+}
+
+// ReplayResult compares the state obtained by replaying an entity's history
+// with the one in StateStorage. Stored is empty when the entity is missing
+// from storage. Err is only set by ReplayAll.
+type ReplayResult struct {
+	EntityID    string
+	Replayed    string
+	Stored      string
+	Drift       bool
+	Rebuilt     bool
+	Divergences []Divergence
+	Err         error
+}
+
+// Verify replays the recorded events of the entity through the definition
+// and reports drift from StateStorage without changing anything. Hooks,
+// actions and guards are not run: a guarded transition is taken when the
+// record reached its target.
+func (f *FSM) Verify(ctx context.Context, entityID string) (ReplayResult, error) {
+	return f.replayEntity(ctx, entityID, ReplayVerify)
+}
+
+// Rebuild replays the recorded events like Verify and, on drift, stores the
+// replayed state, the remembered history states and the state timeouts. In
+// optimistic mode it fails with ErrVersionConflict if the entity changed
+// while it was being replayed.
+func (f *FSM) Rebuild(ctx context.Context, entityID string) (ReplayResult, error) {
+	return f.replayEntity(ctx, entityID, ReplayRebuild)
+}
+
+// ReplayAll verifies or rebuilds every entity in turn. Failures are reported
+// in the result of each entity and do not stop the others.
+func (f *FSM) ReplayAll(ctx context.Context, entityIDs []string, mode ReplayMode) []ReplayResult {
+	results := make([]ReplayResult, 0, len(entityIDs))
+	for _, id := range entityIDs {
+		if err := ctx.Err(); err != nil {
+			results = append(results, ReplayResult{EntityID: id, Err: err})
+			continue
+		}
+		result, err := f.replayEntity(ctx, id, mode)
+		result.Err = err
+		results = append(results, result)
+	}
+	return results
+}
+
+func (f *FSM) replayEntity(ctx context.Context, entityID string, mode ReplayMode) (ReplayResult, error) {
+	result := ReplayResult{EntityID: entityID}
+
+	if mode == ReplayRebuild && f.lockableStorage != nil {
+		unlock, _, err := f.lock(ctx, entityID, NewBasicEvent(InitEvent, nil))
+		if err != nil {
+			return result, err
+		}
+		defer unlock()
+	}
+
+	config, r, divergences, err := f.replay(ctx, entityID)
+	if err != nil {
+		return result, err
+	}
+	result.Replayed = f.formatConfiguration(config)
+	result.Divergences = divergences
+
+	stored, x, version, err := f.getState(ctx, entityID)
+	if err != nil && !errors.Is(err, ErrEntityNotFound) {
+		return result, err
+	}
+	result.Stored = stored
+	result.Drift = result.Replayed != stored
+
+	if !result.Drift || mode != ReplayRebuild {
+		return result, nil
+	}
+
+	if _, err := f.setState(ctx, entityID, result.Replayed, x, version); err != nil {
+		return result, err
+	}
+	if err := f.saveHistory(ctx, r); err != nil {
+		return result, err
+	}
+	result.Rebuilt = true
+	f.logger.Infof("FSM [%s]: rebuilt state '%s' (stored '%s')", entityID, result.Replayed, stored)
+
+	var exited, entered []string
+	if stored != "" {
+		for s := range f.activeStates(f.parseConfiguration(stored)) {
+			exited = append(exited, s)
+		}
+	}
+	for s := range f.activeStates(config) {
+		entered = append(entered, s)
+	}
+	f.updateTimers(ctx, entityID, exited, entered, config)
+
+	if cs, ok := f.storage.(CompletableStorage); ok && f.isCompleted(config) {
+		if err := cs.Complete(ctx, entityID, result.Replayed); err != nil {
+			f.logger.Errorf("FSM [%s]: failed to complete entity in storage: %v", entityID, err)
+		}
+	}
+	return result, nil
+}
+
+// replay runs every recorded event as a single microstep, without the
+// internal events it raises: those were recorded on their own. Records with
+// no From state mark an initialization. Guards are not run again, since
+// recorded payloads may not come back with their original types: a guarded
+// transition is taken when the record reached its target.
+func (f *FSM) replay(ctx context.Context, entityID string) (configuration, *run, []Divergence, error) {
+	if f.history == nil {
+		return nil, nil, nil, errors.New("fsm: no HistoryStore configured")
+	}
+	records, err := f.history.History(ctx, entityID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, nil, fmt.Errorf("%w: no recorded transitions for '%s'", ErrEntityNotFound, entityID)
+	}
+
+	parse := func(value string) (configuration, error) {
+		config := f.parseConfiguration(value)
+		for _, leaf := range config {
+			if _, ok := f.states[leaf]; !ok {
+				return nil, fmt.Errorf("%w: recorded state '%s'", ErrUnknownState, value)
+			}
+		}
+		return config, nil
+	}
+
+	start := records[0].From
+	if start == "" {
+		start = records[0].To
+	}
+	config, err := parse(start)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	r := newRun(entityID)
+	r.dryRun = true
+	ctx = withRun(withEntityID(ctx, entityID), r)
+
+	var divergences []Divergence
+	for i, record := range records {
+		if record.From == "" {
+			if config, err = parse(record.To); err != nil {
+				return nil, nil, nil, err
+			}
+			continue
+		}
+
+		// An unknown recorded state rejects every guard and shows up as a
+		// divergence.
+		recorded, _ := parse(record.To)
+		r.recorded = f.activeStates(recorded)
+		for name := range f.states {
+			if f.historyKind(name) != 0 && r.recorded[f.parentOf(name)] {
+				r.recorded[name] = true
+			}
+		}
+
+		next, st, err := f.microstep(ctx, r, config, NewBasicEvent(record.Event, record.Payload))
+		r.queue = nil
+		if err != nil {
+			divergences = append(divergences, Divergence{Index: i, Record: record, Replayed: f.formatConfiguration(config), Err: err})
+			continue
+		}
+		config = next
+		if st.to != record.To {
+			divergences = append(divergences, Divergence{Index: i, Record: record, Replayed: st.to})
+		}
+	}
+	return config, r, divergences, nil
+}
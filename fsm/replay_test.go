@@ -0,0 +1,199 @@
+package fsm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func newReplayFSM(t *testing.T, storage StateStorage, history HistoryStore) *FSM {
+	t.Helper()
+
+	var acted int
+	def := NewDefinition().
+		Composite("scan", "running", "done").
+		History("scan.history", "scan", ShallowHistory).
+		From("pending").On("start_scan").
+		Do(func(ctx context.Context, id string, event Event) (any, error) {
+			acted++
+			if acted > 1 {
+				t.Error("expected actions not to run during replay")
+			}
+			return nil, nil
+		}).
+		To("scan").
+		From("scan").On("pause").To("paused").
+		From("paused").On("resume").To("scan.history").
+		From("running").On("all_jobs_completed").To("done").
+		From("scan").OnDone().To("completed").
+		Final("done", "completed")
+
+	fsm, err := NewFSM(def.States(),
+		WithStateStorage(storage),
+		WithLogger(&MockLogger{}),
+		WithInitialState("pending"),
+		WithHistoryStore(history),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error creating FSM: %v", err)
+	}
+	return fsm
+}
+
+func TestFSM_Rebuild(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-replay"
+	storage := NewFakeStorage()
+	history := &FakeHistoryStore{}
+	fsm := newReplayFSM(t, storage, history)
+
+	if err := fsm.Init(ctx, entityID); err != nil {
+		t.Fatalf("unexpected error on Init: %v", err)
+	}
+	for _, event := range []string{"start_scan", "pause", "resume", "all_jobs_completed"} {
+		if err := fsm.Trigger(ctx, entityID, NewBasicEvent(event, nil)); err != nil {
+			t.Fatalf("unexpected error on %s: %v", event, err)
+		}
+	}
+
+	result, err := fsm.Verify(ctx, entityID)
+	if err != nil {
+		t.Fatalf("unexpected error on Verify: %v", err)
+	}
+	if result.Drift || result.Replayed != "completed" || len(result.Divergences) != 0 {
+		t.Errorf("expected no drift, got %+v", result)
+	}
+
+	// Lose the state and its remembered history.
+	delete(storage.states, entityID)
+	delete(storage.history, entityID)
+
+	result, err = fsm.Verify(ctx, entityID)
+	if err != nil {
+		t.Fatalf("unexpected error on Verify: %v", err)
+	}
+	if !result.Drift || result.Rebuilt || result.Stored != "" {
+		t.Errorf("expected drift to be reported only, got %+v", result)
+	}
+	if _, err := storage.GetState(ctx, entityID); err == nil {
+		t.Error("expected Verify not to write the state")
+	}
+
+	result, err = fsm.Rebuild(ctx, entityID)
+	if err != nil {
+		t.Fatalf("unexpected error on Rebuild: %v", err)
+	}
+	if !result.Rebuilt {
+		t.Errorf("expected entity to be rebuilt, got %+v", result)
+	}
+	if state, _ := storage.GetState(ctx, entityID); state != "completed" {
+		t.Errorf("expected rebuilt state completed, got %s", state)
+	}
+	if remembered := storage.history[entityID]["scan"]; remembered != "scan/done" {
+		t.Errorf("expected remembered history scan/done, got %s", remembered)
+	}
+}
+
+func TestFSM_ReplayAll(t *testing.T) {
+	ctx := context.Background()
+	storage := NewFakeStorage()
+	history := &FakeHistoryStore{}
+	fsm := newReplayFSM(t, storage, history)
+
+	if err := fsm.Init(ctx, "entity-ok"); err != nil {
+		t.Fatalf("unexpected error on Init: %v", err)
+	}
+	if err := fsm.Init(ctx, "entity-drift"); err != nil {
+		t.Fatalf("unexpected error on Init: %v", err)
+	}
+	// A transition that the definition does not allow.
+	history.records = append(history.records, TransitionRecord{EntityID: "entity-drift", Event: "skip", From: "pending", To: "completed"})
+	storage.SetState(ctx, "entity-drift", "completed")
+
+	results := fsm.ReplayAll(ctx, []string{"entity-ok", "entity-drift", "entity-missing"}, ReplayVerify)
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+
+	if r := results[0]; r.Err != nil || r.Drift {
+		t.Errorf("expected entity-ok to match, got %+v", r)
+	}
+
+	r := results[1]
+	if r.Err != nil || !r.Drift || r.Replayed != "pending" || r.Stored != "completed" {
+		t.Errorf("expected entity-drift to drift, got %+v", r)
+	}
+	if len(r.Divergences) != 1 || !errors.Is(r.Divergences[0].Err, ErrNoTransition) {
+		t.Errorf("expected a divergence on the skip event, got %+v", r.Divergences)
+	}
+
+	if r := results[2]; !errors.Is(r.Err, ErrEntityNotFound) {
+		t.Errorf("expected ErrEntityNotFound for entity-missing, got %v", r.Err)
+	}
+}
+
+// jsonHistoryStore returns payloads as generic JSON values, like a store
+// that persists them as JSON does.
+type jsonHistoryStore struct {
+	FakeHistoryStore
+}
+
+func (s *jsonHistoryStore) Append(ctx context.Context, record TransitionRecord) error {
+	b, err := json.Marshal(record.Payload)
+	if err != nil {
+		return err
+	}
+	record.Payload = nil
+	if err := json.Unmarshal(b, &record.Payload); err != nil {
+		return err
+	}
+	return s.FakeHistoryStore.Append(ctx, record)
+}
+
+type payment struct {
+	Amount int `json:"amount"`
+}
+
+func TestFSM_Replay_TypedGuard(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-replay-typed"
+	storage := NewFakeStorage()
+	history := &jsonHistoryStore{}
+
+	large := func(ctx context.Context, id string, event TypedEvent[string, payment]) (bool, error) {
+		return event.Data().Amount > 100, nil
+	}
+	def := NewMachineDefinition[string, string, payment]().
+		From("pending").On("pay").Guard(large).To("review").
+		From("pending").On("pay").To("approved").
+		Final("review", "approved")
+
+	m, err := NewMachine(def,
+		WithStateStorage(storage),
+		WithLogger(&MockLogger{}),
+		WithInitialState("pending"),
+		WithHistoryStore(history),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error creating machine: %v", err)
+	}
+
+	if err := m.Init(ctx, entityID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := m.Trigger(ctx, entityID, "pay", payment{Amount: 500}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := history.records[1].Payload.(map[string]any); !ok {
+		t.Fatalf("expected the payload to come back as JSON, got %T", history.records[1].Payload)
+	}
+
+	result, err := m.FSM().Verify(ctx, entityID)
+	if err != nil {
+		t.Fatalf("unexpected error on Verify: %v", err)
+	}
+	if result.Drift || result.Replayed != "review" || len(result.Divergences) != 0 {
+		t.Errorf("expected the guarded transition to replay, got %+v", result)
+	}
+}
@@ -0,0 +1,106 @@
+package scxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/rluders/gofsm/fsm"
+)
+
+type xmlDocument struct {
+	XMLName xml.Name `xml:"scxml"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Version string   `xml:"version,attr"`
+	Initial string   `xml:"initial,attr,omitempty"`
+	States  []xmlState
+}
+
+type xmlState struct {
+	XMLName     xml.Name
+	ID          string          `xml:"id,attr"`
+	Type        string          `xml:"type,attr,omitempty"`
+	Transitions []xmlTransition `xml:"transition"`
+	States      []xmlState
+}
+
+type xmlTransition struct {
+	Event  string `xml:"event,attr"`
+	Target string `xml:"target,attr"`
+	Cond   string `xml:"cond,attr,omitempty"`
+}
+
+// Export writes the document as SCXML. Actions, unnamed guards, timeouts and
+// state implementations attached with Definition.Add cannot be expressed and
+// are returned as unsupported; named guards are written as cond attributes.
+func Export(w io.Writer, doc *Document) ([]Unsupported, error) {
+	def := doc.Definition
+	var unsupported []Unsupported
+
+	rules := make(map[string][]fsm.TransitionRule)
+	for _, r := range def.Rules() {
+		rules[r.From] = append(rules[r.From], r)
+	}
+
+	var build func(name, path string) xmlState
+	build = func(name, path string) xmlState {
+		path = fmt.Sprintf("%s/state[%s]", path, name)
+		s := xmlState{XMLName: xml.Name{Local: "state"}, ID: name}
+		switch {
+		case def.HistoryType(name) == fsm.DeepHistory:
+			s.XMLName.Local, s.Type = "history", "deep"
+		case def.HistoryType(name) == fsm.ShallowHistory:
+			s.XMLName.Local, s.Type = "history", "shallow"
+		case def.IsParallel(name):
+			s.XMLName.Local = "parallel"
+		case def.IsFinal(name):
+			s.XMLName.Local = "final"
+		}
+
+		if def.HasImplementation(name) {
+			unsupported = append(unsupported, Unsupported{Path: path, Construct: "state implementation", Reason: "OnEnter, OnExit and HandleEvent skipped"})
+		}
+		if _, event, ok := def.TimeoutOf(name); ok {
+			unsupported = append(unsupported, Unsupported{Path: path, Construct: "timeout", Reason: fmt.Sprintf("timeout raising '%s' skipped", event)})
+		}
+
+		for _, r := range rules[name] {
+			t := xmlTransition{Event: r.Event, Target: r.To, Cond: r.GuardName}
+			if r.Guard != nil && r.GuardName == "" {
+				unsupported = append(unsupported, Unsupported{Path: path, Construct: "guard", Reason: fmt.Sprintf("unnamed guard on '%s' skipped", r.Event)})
+			}
+			if r.Action != nil {
+				unsupported = append(unsupported, Unsupported{Path: path, Construct: "action", Reason: fmt.Sprintf("action on '%s' skipped", r.Event)})
+			}
+			s.Transitions = append(s.Transitions, t)
+		}
+
+		for _, child := range def.Children(name) {
+			s.States = append(s.States, build(child, path))
+		}
+		for _, h := range def.StateNames() {
+			if def.HistoryType(h) != 0 && def.Parent(h) == name {
+				s.States = append(s.States, build(h, path))
+			}
+		}
+		return s
+	}
+
+	out := xmlDocument{Xmlns: Namespace, Version: "1.0", Initial: doc.InitialState}
+	for _, name := range def.StateNames() {
+		if def.Parent(name) == "" {
+			out.States = append(out.States, build(name, "scxml"))
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return unsupported, err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return unsupported, err
+	}
+	_, err := io.WriteString(w, "\n")
+	return unsupported, err
+}
@@ -0,0 +1,308 @@
+// Package scxml converts fsm definitions from and to W3C SCXML documents.
+//
+// States, compound, parallel and final states, history pseudo-states and
+// transitions are supported. Conditions are bound to guards by name with
+// WithGuard, and <raise> inside a transition becomes an action that calls
+// fsm.Raise. Anything else, such as data models, scripts or entry and exit
+// handlers, is listed in the document's Unsupported report instead of being
+// dropped silently.
+//
+// Event descriptors are imported as exact event names. SCXML matches them by
+// prefix, so a transition on "error" also takes "error.send"; such a
+// transition must list every event it handles to behave the same here.
+package scxml
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rluders/gofsm/fsm"
+)
+
+const Namespace = "http://www.w3.org/2005/07/scxml"
+
+var ErrInvalidDocument = errors.New("scxml: invalid document")
+
+// Unsupported describes a construct that was skipped during import or
+// export. Path locates it, e.g. "scxml/state[running]/onentry".
+type Unsupported struct {
+	Path      string
+	Construct string
+	Reason    string
+}
+
+func (u Unsupported) String() string {
+	return fmt.Sprintf("%s: %s: %s", u.Path, u.Construct, u.Reason)
+}
+
+// Document is a machine definition with its initial state.
+type Document struct {
+	Definition   *fsm.Definition
+	InitialState string
+	Unsupported  []Unsupported
+}
+
+// NewFSM creates the machine, starting in the document's initial state.
+func (d *Document) NewFSM(opts ...fsm.Option) (*fsm.FSM, error) {
+	return d.Definition.NewFSM(d.InitialState, opts...)
+}
+
+type Option func(*importer)
+
+// WithGuard binds the transitions whose cond attribute equals cond to guard.
+func WithGuard(cond string, guard fsm.Guard) Option {
+	return func(im *importer) {
+		im.guards[cond] = guard
+	}
+}
+
+// node is a generic XML element, kept in document order.
+type node struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []node     `xml:",any"`
+}
+
+func (n node) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name && a.Name.Space == "" {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+type importer struct {
+	def         *fsm.Definition
+	guards      map[string]fsm.Guard
+	declared    map[string]bool
+	transitions []pendingTransition
+	unsupported []Unsupported
+}
+
+type pendingTransition struct {
+	source string
+	node   node
+	path   string
+}
+
+func (im *importer) unsupportedf(path, construct, format string, args ...any) {
+	im.unsupported = append(im.unsupported, Unsupported{Path: path, Construct: construct, Reason: fmt.Sprintf(format, args...)})
+}
+
+// Import reads an SCXML document into a Definition.
+func Import(r io.Reader, opts ...Option) (*Document, error) {
+	var root node
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDocument, err)
+	}
+	if root.XMLName.Local != "scxml" {
+		return nil, fmt.Errorf("%w: root element is <%s>, expected <scxml>", ErrInvalidDocument, root.XMLName.Local)
+	}
+
+	im := &importer{
+		def:      fsm.NewDefinition(),
+		guards:   make(map[string]fsm.Guard),
+		declared: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(im)
+	}
+
+	for _, a := range []string{"datamodel", "binding"} {
+		if v := root.attr(a); v != "" && !(a == "datamodel" && v == "null") {
+			im.unsupportedf("scxml", "@"+a, "attribute ignored")
+		}
+	}
+
+	children, err := im.states(root, "", "scxml")
+	if err != nil {
+		return nil, err
+	}
+
+	initial := root.attr("initial")
+	if initial == "" && len(children) > 0 {
+		initial = children[0]
+	}
+	if strings.Contains(initial, " ") {
+		return nil, fmt.Errorf("%w: scxml: multiple initial states are not supported", ErrInvalidDocument)
+	}
+	if initial != "" && !im.declared[initial] {
+		return nil, fmt.Errorf("%w: scxml: %w '%s'", ErrInvalidDocument, fsm.ErrUnknownState, initial)
+	}
+
+	for _, t := range im.transitions {
+		if err := im.transition(t); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Document{Definition: im.def, InitialState: initial, Unsupported: im.unsupported}, nil
+}
+
+// states declares the states below n and returns its direct children, with
+// the initial child first.
+func (im *importer) states(n node, parent, path string) ([]string, error) {
+	var children []string
+	initial := n.attr("initial")
+
+	for _, c := range n.Children {
+		kind := c.XMLName.Local
+		id := c.attr("id")
+		childPath := fmt.Sprintf("%s/%s[%s]", path, kind, id)
+
+		switch kind {
+		case "state", "parallel", "final":
+			if id == "" {
+				im.unsupportedf(path+"/"+kind, kind, "states without an id are skipped")
+				continue
+			}
+			if im.declared[id] {
+				return nil, fmt.Errorf("%w: %s: duplicate state '%s'", ErrInvalidDocument, childPath, id)
+			}
+			im.declared[id] = true
+			im.def.Declare(id)
+			children = append(children, id)
+
+			grandchildren, err := im.states(c, id, childPath)
+			if err != nil {
+				return nil, err
+			}
+			switch kind {
+			case "parallel":
+				im.def.Parallel(id, grandchildren...)
+			case "final":
+				im.def.Final(id)
+			default:
+				im.def.Composite(id, grandchildren...)
+			}
+
+		case "history":
+			if parent == "" || n.XMLName.Local != "state" {
+				return nil, fmt.Errorf("%w: %s: history must be inside a compound state", ErrInvalidDocument, childPath)
+			}
+			if id == "" {
+				return nil, fmt.Errorf("%w: %s: history requires an id", ErrInvalidDocument, childPath)
+			}
+			history := fsm.ShallowHistory
+			if c.attr("type") == "deep" {
+				history = fsm.DeepHistory
+			}
+			if len(c.Children) > 0 {
+				im.unsupportedf(childPath, "history default transition", "the parent's initial state is used instead")
+			}
+			im.declared[id] = true
+			im.def.History(id, parent, history)
+
+		case "transition":
+			if parent == "" {
+				return nil, fmt.Errorf("%w: %s: transitions must be inside a state", ErrInvalidDocument, path)
+			}
+			im.transitions = append(im.transitions, pendingTransition{source: parent, node: c, path: path + "/transition"})
+
+		case "initial":
+			for _, t := range c.Children {
+				if t.XMLName.Local == "transition" {
+					initial = t.attr("target")
+				}
+			}
+
+		case "onentry", "onexit", "invoke", "datamodel", "script", "donedata":
+			im.unsupportedf(path+"/"+kind, kind, "executable content and data are not supported")
+
+		default:
+			im.unsupportedf(path+"/"+kind, kind, "unknown element")
+		}
+	}
+
+	if initial != "" && n.XMLName.Local == "state" {
+		found := false
+		for i, child := range children {
+			if child == initial {
+				copy(children[1:i+1], children[:i])
+				children[0] = initial
+				found = true
+				break
+			}
+		}
+		if !found {
+			im.unsupportedf(path, "initial", "'%s' is not a child state, the first child is used instead", initial)
+		}
+	}
+	return children, nil
+}
+
+func (im *importer) transition(t pendingTransition) error {
+	n := t.node
+	target := n.attr("target")
+	events := strings.Fields(n.attr("event"))
+
+	switch {
+	case target == "":
+		im.unsupportedf(t.path, "targetless transition", "skipped")
+		return nil
+	case len(strings.Fields(target)) > 1:
+		im.unsupportedf(t.path, "multiple targets", "skipped")
+		return nil
+	case len(events) == 0:
+		im.unsupportedf(t.path, "eventless transition", "skipped")
+		return nil
+	case !im.declared[target]:
+		return fmt.Errorf("%w: %s: %w '%s'", ErrInvalidDocument, t.path, fsm.ErrUnknownState, target)
+	}
+	if n.attr("type") == "internal" {
+		im.unsupportedf(t.path, "@type", "internal transitions are taken as external")
+	}
+
+	var guard fsm.Guard
+	cond := n.attr("cond")
+	if cond != "" {
+		guard = im.guards[cond]
+		if guard == nil {
+			im.unsupportedf(t.path, "@cond", "no guard bound to '%s', transition skipped", cond)
+			return nil
+		}
+	}
+
+	var raise []string
+	for _, c := range n.Children {
+		if c.XMLName.Local == "raise" && c.attr("event") != "" {
+			raise = append(raise, c.attr("event"))
+			continue
+		}
+		im.unsupportedf(t.path+"/"+c.XMLName.Local, c.XMLName.Local, "executable content is not supported")
+	}
+
+	for _, event := range events {
+		if event == "*" || strings.HasSuffix(event, ".*") {
+			im.unsupportedf(t.path, "@event", "wildcard '%s' skipped", event)
+			continue
+		}
+		// Descriptors match by prefix in SCXML but exactly here, see the
+		// package documentation.
+		b := im.def.From(t.source).On(strings.TrimSuffix(event, "."))
+		if guard != nil {
+			b.GuardNamed(cond, guard)
+		}
+		if len(raise) > 0 {
+			b.Do(raiseAction(raise))
+		}
+		b.To(target)
+	}
+	return nil
+}
+
+func raiseAction(events []string) fsm.Action {
+	return func(ctx context.Context, entityID string, event fsm.Event) (any, error) {
+		for _, name := range events {
+			if err := fsm.Raise(ctx, fsm.NewBasicEvent(name, nil)); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+}
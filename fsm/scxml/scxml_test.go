@@ -0,0 +1,182 @@
+package scxml
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rluders/gofsm/fsm"
+	"github.com/rluders/gofsm/storage/memory"
+)
+
+const scanSCXML = `<?xml version="1.0" encoding="UTF-8"?>
+<scxml xmlns="http://www.w3.org/2005/07/scxml" version="1.0" initial="pending" datamodel="ecmascript">
+  <datamodel><data id="count" expr="0"/></datamodel>
+  <state id="pending">
+    <transition event="start_scan" target="scanning" cond="hasTargets"/>
+    <transition event="cancel" cond="_event.data.force" target="cancelled"/>
+  </state>
+  <parallel id="scanning">
+    <state id="jobs">
+      <state id="working">
+        <onentry><log expr="'working'"/></onentry>
+        <transition event="all_jobs_completed" target="jobs_done">
+          <raise event="jobs.finished"/>
+        </transition>
+      </state>
+      <final id="jobs_done"/>
+    </state>
+    <state id="notify" initial="unsent">
+      <state id="sent">
+        <transition event="jobs.finished" target="notify_done"/>
+      </state>
+      <state id="unsent">
+        <transition event="notified" target="sent"/>
+      </state>
+      <final id="notify_done"/>
+      <history id="notify_history" type="deep"/>
+    </state>
+    <transition event="done.state.scanning" target="completed"/>
+  </parallel>
+  <final id="completed"/>
+  <final id="cancelled"/>
+</scxml>`
+
+func allow(ctx context.Context, entityID string, event fsm.Event) (bool, error) {
+	return true, nil
+}
+
+func noop(ctx context.Context, entityID string, event fsm.Event) (any, error) {
+	return nil, nil
+}
+
+func TestImport(t *testing.T) {
+	doc, err := Import(strings.NewReader(scanSCXML), WithGuard("hasTargets", allow))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	def := doc.Definition
+	if doc.InitialState != "pending" {
+		t.Errorf("expected initial state pending, got %s", doc.InitialState)
+	}
+	if !def.IsParallel("scanning") || !def.IsFinal("completed") || !def.IsFinal("jobs_done") {
+		t.Error("expected parallel and final states to be imported")
+	}
+	if children := def.Children("notify"); len(children) != 3 || children[0] != "unsent" {
+		t.Errorf("expected unsent to be the initial child of notify, got %v", children)
+	}
+	if def.HistoryType("notify_history") != fsm.DeepHistory {
+		t.Error("expected deep history to be imported")
+	}
+
+	constructs := make(map[string]bool)
+	for _, u := range doc.Unsupported {
+		constructs[u.Construct] = true
+	}
+	for _, c := range []string{"@datamodel", "datamodel", "onentry", "@cond"} {
+		if !constructs[c] {
+			t.Errorf("expected %s to be reported as unsupported, got %v", c, doc.Unsupported)
+		}
+	}
+
+	ctx := context.Background()
+	machine, err := doc.NewFSM(fsm.WithStateStorage(memory.NewMemoryStorage()))
+	if err != nil {
+		t.Fatalf("unexpected error creating FSM: %v", err)
+	}
+	if err := machine.Init(ctx, "scan-1"); err != nil {
+		t.Fatalf("unexpected error on Init: %v", err)
+	}
+	for _, event := range []string{"start_scan", "notified", "all_jobs_completed"} {
+		if err := machine.Trigger(ctx, "scan-1", fsm.NewBasicEvent(event, nil)); err != nil {
+			t.Fatalf("unexpected error on %s: %v", event, err)
+		}
+	}
+	if state, _ := machine.CurrentState(ctx, "scan-1"); state != "completed" {
+		t.Errorf("expected state completed, got %s", state)
+	}
+}
+
+func TestImport_Invalid(t *testing.T) {
+	docs := map[string]string{
+		"root":   `<machine/>`,
+		"target": `<scxml><state id="a"><transition event="go" target="b"/></state></scxml>`,
+		"dup":    `<scxml><state id="a"/><state id="a"/></scxml>`,
+	}
+	for name, doc := range docs {
+		if _, err := Import(strings.NewReader(doc)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// runner has behaviour of its own, which SCXML cannot express.
+type runner struct{}
+
+func (runner) Name() string {
+	return "running"
+}
+
+func (runner) OnEnter(ctx context.Context, event fsm.Event) error {
+	return nil
+}
+
+func (runner) OnExit(ctx context.Context, event fsm.Event) error {
+	return nil
+}
+
+func TestExport_RoundTrip(t *testing.T) {
+	def := fsm.NewDefinition().
+		Composite("active", "idle", "running").
+		Add(runner{}).
+		History("active_history", "active", fsm.ShallowHistory).
+		From("idle").On("start").GuardNamed("ready", allow).To("running").
+		From("running").On("stop").Do(noop).To("stopped").
+		From("stopped").On("resume").To("active_history").
+		Final("stopped").
+		Timeout("idle", 0, "expire")
+
+	var buf bytes.Buffer
+	unsupported, err := Export(&buf, &Document{Definition: def, InitialState: "active"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	constructs := make(map[string]bool)
+	for _, u := range unsupported {
+		constructs[u.Construct] = true
+	}
+	if len(unsupported) != 3 || !constructs["action"] || !constructs["timeout"] || !constructs["state implementation"] {
+		t.Errorf("expected action, timeout and state implementation to be reported, got %v", unsupported)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		`<scxml xmlns="http://www.w3.org/2005/07/scxml" version="1.0" initial="active">`,
+		`<transition event="start" target="running" cond="ready"></transition>`,
+		`<history id="active_history" type="shallow"></history>`,
+		`<final id="stopped">`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	doc, err := Import(&buf, WithGuard("ready", allow))
+	if err != nil {
+		t.Fatalf("unexpected error importing export: %v", err)
+	}
+	if len(doc.Unsupported) != 0 {
+		t.Errorf("expected clean import, got %v", doc.Unsupported)
+	}
+	if got, want := doc.Definition.StateNames(), def.StateNames(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected states %v, got %v", want, got)
+	}
+	if rules := doc.Definition.Rules(); len(rules) != 3 || rules[0].GuardName != "ready" {
+		t.Errorf("expected 3 rules with named guard, got %+v", rules)
+	}
+	if unsupported, err := Export(&buf, doc); err != nil || len(unsupported) != 0 {
+		t.Errorf("expected imported document to export cleanly, got %v, %v", unsupported, err)
+	}
+}
@@ -0,0 +1,101 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// GuardResult is a guard evaluated during Simulate. Target and Guard are
+// only known for transitions declared on a Definition.
+type GuardResult struct {
+	State  string
+	Event  string
+	Target string
+	Guard  string
+	Passed bool
+	Err    error
+}
+
+// SimulationStep is one event processed by Simulate. Internal steps handle
+// the completion events raised by earlier steps.
+type SimulationStep struct {
+	Event    string
+	From     string
+	To       string
+	Changed  bool
+	Internal bool
+	Guards   []GuardResult
+	Err      error
+}
+
+// Simulate replays events starting from startState, a value as persisted in
+// StateStorage, or the initial state when empty. Guards are evaluated, but
+// hooks and actions are not run and nothing is read from or written to
+// storage. An event that fails is recorded in its step and the simulation
+// goes on from the same state.
+func (f *FSM) Simulate(ctx context.Context, startState string, events []Event) ([]SimulationStep, error) {
+	if startState == "" {
+		if f.initialState == "" {
+			return nil, errors.New("fsm: no start state given and no initial state configured")
+		}
+		startState = f.initialState
+	}
+
+	targets := f.parseConfiguration(startState)
+	for _, leaf := range targets {
+		if _, ok := f.states[leaf]; !ok {
+			return nil, fmt.Errorf("%w: start state '%s'", ErrUnknownState, startState)
+		}
+	}
+	config := f.apply(nil, plannedTransition{entries: f.entrySet("", targets)})
+
+	r := newRun("")
+	r.dryRun = true
+	ctx = withRun(ctx, r)
+
+	var steps []SimulationStep
+	process := func(event Event, internal bool) {
+		r.guards = nil
+		st := SimulationStep{Event: event.Name(), From: f.formatConfiguration(config), Internal: internal}
+		st.To = st.From
+
+		if f.isCompleted(config) {
+			st.Err = &TransitionError{From: st.From, Event: event.Name(), Err: ErrEntityCompleted}
+		} else if next, s, err := f.microstep(ctx, r, config, event); err != nil {
+			st.Err = err
+		} else {
+			config = next
+			st.To = s.to
+			st.Changed = s.changed
+		}
+		st.Guards = r.guards
+
+		// Like Trigger, ignore completion events nothing listens to.
+		if internal && errors.Is(st.Err, ErrNoTransition) {
+			return
+		}
+		steps = append(steps, st)
+	}
+
+	for _, event := range events {
+		process(event, false)
+		for depth := 1; len(r.queue) > 0; depth++ {
+			if depth > f.maxChainDepth {
+				steps = append(steps, SimulationStep{
+					Event:    r.queue[0].Name(),
+					From:     f.formatConfiguration(config),
+					To:       f.formatConfiguration(config),
+					Internal: true,
+					Err:      ErrChainDepthExceeded,
+				})
+				r.queue = nil
+				break
+			}
+			internal := r.queue[0]
+			r.queue = r.queue[1:]
+			process(internal, true)
+		}
+	}
+	return steps, nil
+}
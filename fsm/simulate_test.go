@@ -0,0 +1,103 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFSM_Simulate(t *testing.T) {
+	ctx := context.Background()
+	storage := NewFakeStorage()
+
+	var acted int
+	var calls []string
+	hasTargets := func(ctx context.Context, id string, event Event) (bool, error) {
+		return event.Payload() != nil, nil
+	}
+	def := NewDefinition().
+		Add(
+			&LifecycleRecorder{name: "pending", calls: &calls},
+			&LifecycleRecorder{name: "running", calls: &calls},
+		).
+		Composite("scan", "pending", "running", "done").
+		From("pending").On("start_scan").GuardNamed("hasTargets", hasTargets).
+		Do(func(ctx context.Context, id string, event Event) (any, error) {
+			acted++
+			return nil, nil
+		}).
+		To("running").
+		From("running").On("all_jobs_completed").To("done").
+		From("scan").OnDone().To("completed").
+		Final("done", "completed")
+
+	fsm, err := NewFSM(def.States(),
+		WithStateStorage(storage),
+		WithLogger(&MockLogger{}),
+		WithInitialState("scan"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error creating FSM: %v", err)
+	}
+
+	steps, err := fsm.Simulate(ctx, "", []Event{
+		NewBasicEvent("start_scan", nil),
+		NewBasicEvent("start_scan", []string{"10.0.0.1"}),
+		NewBasicEvent("unknown", nil),
+		NewBasicEvent("all_jobs_completed", nil),
+		NewBasicEvent("start_scan", nil),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []struct {
+		event    string
+		from, to string
+		internal bool
+		err      error
+	}{
+		{"start_scan", "scan/pending", "scan/pending", false, ErrGuardRejected},
+		{"start_scan", "scan/pending", "scan/running", false, nil},
+		{"unknown", "scan/running", "scan/running", false, ErrNoTransition},
+		{"all_jobs_completed", "scan/running", "scan/done", false, nil},
+		{DoneEvent("scan"), "scan/done", "completed", true, nil},
+		{"start_scan", "completed", "completed", false, ErrEntityCompleted},
+	}
+	if len(steps) != len(want) {
+		t.Fatalf("expected %d steps, got %d: %+v", len(want), len(steps), steps)
+	}
+	for i, w := range want {
+		st := steps[i]
+		if st.Event != w.event || st.From != w.from || st.To != w.to || st.Internal != w.internal {
+			t.Errorf("step %d: expected %s %s → %s (internal %v), got %+v", i, w.event, w.from, w.to, w.internal, st)
+		}
+		if (w.err == nil) != (st.Err == nil) || (w.err != nil && !errors.Is(st.Err, w.err)) {
+			t.Errorf("step %d: expected error %v, got %v", i, w.err, st.Err)
+		}
+	}
+
+	if g := steps[0].Guards; len(g) != 1 || g[0].Guard != "hasTargets" || g[0].Passed || g[0].Target != "running" {
+		t.Errorf("expected rejected hasTargets guard, got %+v", g)
+	}
+	if g := steps[1].Guards; len(g) != 1 || !g[0].Passed {
+		t.Errorf("expected passed guard, got %+v", g)
+	}
+
+	if acted != 0 || len(calls) != 0 {
+		t.Errorf("expected actions and hooks not to run, got %d actions and %v", acted, calls)
+	}
+	if len(storage.states) != 0 {
+		t.Errorf("expected storage to be untouched, got %v", storage.states)
+	}
+}
+
+func TestFSM_SimulateUnknownStart(t *testing.T) {
+	fsm, err := NewFSM(NewDefinition().From("a").On("go").To("b").States(), WithStateStorage(NewFakeStorage()))
+	if err != nil {
+		t.Fatalf("unexpected error creating FSM: %v", err)
+	}
+	if _, err := fsm.Simulate(context.Background(), "missing", nil); !errors.Is(err, ErrUnknownState) {
+		t.Errorf("expected ErrUnknownState, got %v", err)
+	}
+}
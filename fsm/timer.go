@@ -0,0 +1,113 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+func (f *FSM) timeoutOf(state string) *stateTimeout {
+	if s, ok := f.declared(state); ok {
+		return s.timeout
+	}
+	return nil
+}
+
+// updateTimers cancels the timeouts of exited states and schedules those of
+// states entered and still active. Failures are logged: the transition has
+// already been stored.
+func (f *FSM) updateTimers(ctx context.Context, entityID string, exited, entered []string, config configuration) {
+	if f.timers == nil {
+		return
+	}
+
+	cancelled := make(map[string]bool)
+	for _, s := range exited {
+		timeout := f.timeoutOf(s)
+		if timeout == nil || cancelled[s] {
+			continue
+		}
+		cancelled[s] = true
+		if err := f.timers.Cancel(ctx, Timer{EntityID: entityID, State: s, Event: timeout.event}); err != nil {
+			f.logger.Errorf("FSM [%s]: failed to cancel timeout of state '%s': %v", entityID, s, err)
+		}
+	}
+
+	active := f.activeStates(config)
+	scheduled := make(map[string]bool)
+	now := time.Now()
+	for _, s := range entered {
+		timeout := f.timeoutOf(s)
+		if timeout == nil || scheduled[s] || !active[s] {
+			continue
+		}
+		scheduled[s] = true
+		timer := Timer{EntityID: entityID, State: s, Event: timeout.event, FireAt: now.Add(timeout.after)}
+		if err := f.timers.Schedule(ctx, timer); err != nil {
+			f.logger.Errorf("FSM [%s]: failed to schedule timeout of state '%s': %v", entityID, s, err)
+		}
+	}
+}
+
+// FireDueTimers delivers every timer due at now through Trigger and returns
+// how many were delivered. Timers whose state is no longer active are
+// dropped. Timers that failed for a reason that may go away, see retryable,
+// are scheduled again to be retried on the next poll; the others are
+// dropped.
+func (f *FSM) FireDueTimers(ctx context.Context, now time.Time) (int, error) {
+	if f.timers == nil {
+		return 0, nil
+	}
+
+	timers, err := f.timers.Due(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, t := range timers {
+		_, err := f.trigger(ctx, t.EntityID, NewBasicEvent(t.Event, nil), t.State)
+		if err == nil {
+			delivered++
+			continue
+		}
+		if !retryable(err) {
+			f.logger.Errorf("FSM [%s]: timeout event '%s' failed: %v", t.EntityID, t.Event, err)
+			continue
+		}
+
+		f.logger.Errorf("FSM [%s]: timeout event '%s' failed, rescheduling: %v", t.EntityID, t.Event, err)
+		if err := f.timers.Schedule(context.WithoutCancel(ctx), t); err != nil {
+			f.logger.Errorf("FSM [%s]: failed to reschedule timeout event '%s': %v", t.EntityID, t.Event, err)
+		}
+	}
+	return delivered, nil
+}
+
+// retryable reports whether handling an event failed for a transient reason:
+// the lock or the storage were unavailable, the entity changed concurrently
+// or the context ended.
+func retryable(err error) bool {
+	return errors.Is(err, ErrLockNotAcquired) ||
+		errors.Is(err, ErrStorageFailed) ||
+		errors.Is(err, ErrVersionConflict) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// RunTimers polls the TimerStore every interval until ctx is done.
+func (f *FSM) RunTimers(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			if _, err := f.FireDueTimers(ctx, now); err != nil {
+				f.logger.Errorf("FSM: failed to read due timers: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package fsm
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type FakeTimerStore struct {
+	mu     sync.Mutex
+	timers map[string]Timer
+}
+
+func NewFakeTimerStore() *FakeTimerStore {
+	return &FakeTimerStore{timers: make(map[string]Timer)}
+}
+
+func (s *FakeTimerStore) Schedule(ctx context.Context, timer Timer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timers[timer.EntityID+"/"+timer.State] = timer
+	return nil
+}
+
+func (s *FakeTimerStore) Cancel(ctx context.Context, timer Timer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.timers, timer.EntityID+"/"+timer.State)
+	return nil
+}
+
+func (s *FakeTimerStore) Due(ctx context.Context, now time.Time) ([]Timer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []Timer
+	for key, timer := range s.timers {
+		if !timer.FireAt.After(now) {
+			due = append(due, timer)
+			delete(s.timers, key)
+		}
+	}
+	return due, nil
+}
+
+func newTimeoutFSM(t *testing.T, storage StateStorage, timers TimerStore) *FSM {
+	t.Helper()
+
+	def := NewDefinition().
+		From("pending").On("start").To("running").
+		From("running").On("finish").To("completed").
+		From("running").On("timeout").To("timed_out").
+		Timeout("running", 30*time.Minute, "timeout").
+		Final("completed", "timed_out")
+
+	fsm, err := NewFSM(def.States(),
+		WithStateStorage(storage),
+		WithLogger(&MockLogger{}),
+		WithTimerStore(timers),
+	)
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+	return fsm
+}
+
+func TestFSM_Timeout(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-timeout"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "pending")
+	timers := NewFakeTimerStore()
+	fsm := newTimeoutFSM(t, storage, timers)
+
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("start", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	timer, ok := timers.timers[entityID+"/running"]
+	if !ok {
+		t.Fatal("expected timeout to be scheduled")
+	}
+	if timer.Event != "timeout" || time.Until(timer.FireAt) < 29*time.Minute {
+		t.Errorf("unexpected timer: %+v", timer)
+	}
+
+	fired, err := fsm.FireDueTimers(ctx, time.Now())
+	if err != nil || fired != 0 {
+		t.Fatalf("expected no due timers, got %d (%v)", fired, err)
+	}
+
+	fired, err = fsm.FireDueTimers(ctx, time.Now().Add(time.Hour))
+	if err != nil || fired != 1 {
+		t.Fatalf("expected one due timer, got %d (%v)", fired, err)
+	}
+
+	state, _ := storage.GetState(ctx, entityID)
+	if state != "timed_out" {
+		t.Errorf("expected state %q, got %q", "timed_out", state)
+	}
+}
+
+func TestFSM_Timeout_CancelledOnExit(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-timeout-cancel"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "pending")
+	timers := NewFakeTimerStore()
+	fsm := newTimeoutFSM(t, storage, timers)
+
+	for _, name := range []string{"start", "finish"} {
+		if err := fsm.Trigger(ctx, entityID, NewBasicEvent(name, nil)); err != nil {
+			t.Fatalf("unexpected error on %q: %v", name, err)
+		}
+	}
+
+	if len(timers.timers) != 0 {
+		t.Errorf("expected timeout to be cancelled, got %v", timers.timers)
+	}
+}
+
+func TestFSM_Timeout_StaleTimerDropped(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-timeout-stale"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "pending")
+	timers := NewFakeTimerStore()
+	fsm := newTimeoutFSM(t, storage, timers)
+
+	timers.Schedule(ctx, Timer{EntityID: entityID, State: "running", Event: "timeout", FireAt: time.Now()})
+
+	if _, err := fsm.FireDueTimers(ctx, time.Now()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	state, _ := storage.GetState(ctx, entityID)
+	if state != "pending" {
+		t.Errorf("expected state %q, got %q", "pending", state)
+	}
+}
+
+func TestFSM_Timeout_RescheduledOnLockFailure(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-timeout-locked"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "running")
+	timers := NewFakeTimerStore()
+	timers.Schedule(ctx, Timer{EntityID: entityID, State: "running", Event: "timeout", FireAt: time.Now()})
+
+	def := NewDefinition().
+		From("running").On("timeout").To("timed_out").
+		Timeout("running", 30*time.Minute, "timeout").
+		Final("timed_out")
+
+	fsm, err := NewFSM(def.States(),
+		WithAutoLock(&FakeLockStorage{StateStorage: storage, failCount: 1}, LockRetryConfig{}, nil),
+		WithLogger(&MockLogger{}),
+		WithTimerStore(timers),
+	)
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+
+	delivered, err := fsm.FireDueTimers(ctx, time.Now())
+	if err != nil || delivered != 0 {
+		t.Fatalf("expected no timer delivered, got %d, %v", delivered, err)
+	}
+	if state, _ := storage.GetState(ctx, entityID); state != "running" {
+		t.Fatalf("expected entity to still be running, got %s", state)
+	}
+
+	delivered, err = fsm.FireDueTimers(ctx, time.Now())
+	if err != nil || delivered != 1 {
+		t.Fatalf("expected the rescheduled timer to be delivered, got %d, %v", delivered, err)
+	}
+	if state, _ := storage.GetState(ctx, entityID); state != "timed_out" {
+		t.Errorf("expected entity to time out, got %s", state)
+	}
+}
+
+func TestFSM_Timeout_RearmedOnReentry(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-timeout-reentry"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "job/queued")
+	timers := NewFakeTimerStore()
+	timers.Schedule(ctx, Timer{EntityID: entityID, State: "job", Event: "timeout", FireAt: time.Now().Add(time.Minute)})
+
+	def := NewDefinition().
+		Composite("job", "queued", "processing").
+		From("job").On("restart").To("job").
+		From("job").On("timeout").To("timed_out").
+		Timeout("job", 30*time.Minute, "timeout").
+		Final("timed_out")
+
+	fsm, err := NewFSM(def.States(),
+		WithStateStorage(storage),
+		WithLogger(&MockLogger{}),
+		WithTimerStore(timers),
+	)
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("restart", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state, _ := storage.GetState(ctx, entityID); state != "job/queued" {
+		t.Errorf("expected state %q, got %q", "job/queued", state)
+	}
+
+	timer, ok := timers.timers[entityID+"/job"]
+	if !ok || time.Until(timer.FireAt) < 29*time.Minute {
+		t.Errorf("expected the timeout to be re-armed, got %+v", timer)
+	}
+}
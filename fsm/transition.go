@@ -1,6 +1,14 @@
 package fsm
 
+import "context"
+
+// Action runs between the source state's OnExit and the target state's
+// OnEnter. A non-nil result replaces Transition.Output.
+type Action func(ctx context.Context, entityID string, event Event) (any, error)
+
 type Transition struct {
 	NextState string
 	Output    any
+	Guard     Guard
+	Action    Action
 }
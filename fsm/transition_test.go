@@ -0,0 +1,90 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFSM_Trigger_Action(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-action"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "pending")
+
+	var calls []string
+	action := func(ctx context.Context, id string, event Event) (any, error) {
+		calls = append(calls, "action:"+id)
+		return "dispatched", nil
+	}
+
+	var hookOutput any
+	hook := func(ctx context.Context, entityID, from, to string, event Event) {
+		hookOutput = TransitionOutput(ctx)
+	}
+
+	def := NewDefinition().
+		Add(
+			&LifecycleRecorder{name: "pending", calls: &calls},
+			&LifecycleRecorder{name: "running", calls: &calls},
+		).
+		From("pending").On("start").Do(action).To("running")
+
+	fsm, err := NewFSM(def.States(),
+		WithStateStorage(storage),
+		WithLogger(&MockLogger{}),
+		WithTransitionHook(hook),
+	)
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+
+	result, err := fsm.TriggerWithResult(ctx, entityID, NewBasicEvent("start", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Output != "dispatched" {
+		t.Errorf("expected result output %q, got %v", "dispatched", result.Output)
+	}
+
+	expected := []string{"exit:pending", "action:" + entityID, "enter:running"}
+	if len(calls) != len(expected) {
+		t.Fatalf("expected calls %v, got %v", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Fatalf("expected calls %v, got %v", expected, calls)
+		}
+	}
+
+	if hookOutput != "dispatched" {
+		t.Errorf("expected hook output %q, got %v", "dispatched", hookOutput)
+	}
+}
+
+func TestFSM_Trigger_ActionError(t *testing.T) {
+	ctx := context.Background()
+	entityID := "entity-action-error"
+	storage := NewFakeStorage()
+	storage.SetState(ctx, entityID, "pending")
+
+	action := func(ctx context.Context, id string, event Event) (any, error) {
+		return nil, errors.New("dispatch failed")
+	}
+
+	def := NewDefinition().From("pending").On("start").Do(action).To("running")
+
+	fsm, err := NewFSM(def.States(), WithStateStorage(storage), WithLogger(&MockLogger{}))
+	if err != nil {
+		t.Fatalf("failed to create FSM: %v", err)
+	}
+
+	if err := fsm.Trigger(ctx, entityID, NewBasicEvent("start", nil)); err == nil {
+		t.Fatal("expected action error, got nil")
+	}
+
+	state, _ := storage.GetState(ctx, entityID)
+	if state != "pending" {
+		t.Errorf("expected state %q, got %q", "pending", state)
+	}
+}
@@ -0,0 +1,234 @@
+package fsm
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ProblemKind string
+
+const (
+	DuplicateState   ProblemKind = "duplicate state"
+	NilState         ProblemKind = "nil state"
+	UnreachableState ProblemKind = "unreachable state"
+	DeadEndState     ProblemKind = "dead-end state"
+	UndefinedTarget  ProblemKind = "undefined target"
+	InvalidName      ProblemKind = "invalid state name"
+)
+
+// ValidationProblem is a single issue found in the states given to NewFSM.
+type ValidationProblem struct {
+	Kind    ProblemKind
+	State   string
+	Message string
+}
+
+func (p ValidationProblem) String() string {
+	return p.Message
+}
+
+// ValidationError lists every problem found by Validate. It matches
+// ErrInvalidDefinition with errors.Is.
+type ValidationError struct {
+	Problems []ValidationProblem
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		messages[i] = p.Message
+	}
+	return fmt.Sprintf("%v: %s", ErrInvalidDefinition, strings.Join(messages, "; "))
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidDefinition
+}
+
+// Validate checks the machine graph. Transitions are only known for states
+// built with a Definition; when any state relies on its own HandleEvent,
+// unreachable states cannot be detected and are not reported.
+func (f *FSM) Validate() error {
+	problems := append([]ValidationProblem(nil), f.problems...)
+
+	names := f.stateNames()
+	problems = append(problems, f.nameProblems(names)...)
+
+	rules := f.rules(names)
+	outgoing := make(map[string][]TransitionRule)
+	for _, r := range rules {
+		if _, ok := f.states[r.From]; !ok {
+			continue
+		}
+		if _, ok := f.states[r.To]; !ok {
+			problems = append(problems, ValidationProblem{
+				Kind:    UndefinedTarget,
+				State:   r.From,
+				Message: fmt.Sprintf("transition from '%s' on '%s' targets undefined state '%s'", r.From, r.Event, r.To),
+			})
+			continue
+		}
+		outgoing[r.From] = append(outgoing[r.From], r)
+	}
+
+	opaque := false
+	for _, name := range names {
+		if f.isOpaque(name) {
+			opaque = true
+		}
+	}
+
+	if f.initialState != "" && !opaque {
+		reachable := f.reachable(outgoing)
+		for _, name := range names {
+			if !reachable[name] && f.historyKind(name) == 0 {
+				problems = append(problems, ValidationProblem{
+					Kind:    UnreachableState,
+					State:   name,
+					Message: fmt.Sprintf("state '%s' is unreachable from '%s'", name, f.initialState),
+				})
+			}
+		}
+	}
+
+	for _, name := range names {
+		if len(f.childrenOf(name)) > 0 || f.isFinal(name) || f.historyKind(name) != 0 || f.isOpaque(name) {
+			continue
+		}
+		exits := false
+		for _, s := range f.lineage(name) {
+			if len(outgoing[s]) > 0 {
+				exits = true
+				break
+			}
+		}
+		if !exits {
+			problems = append(problems, ValidationProblem{
+				Kind:    DeadEndState,
+				State:   name,
+				Message: fmt.Sprintf("state '%s' is not final and has no outgoing transitions", name),
+			})
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// stateNames returns the names of the states in document order.
+func (f *FSM) stateNames() []string {
+	names := make([]string, 0, len(f.states))
+	for name := range f.states {
+		names = append(names, name)
+	}
+	f.sortStates(names)
+	return names
+}
+
+// nameProblems reports the names that cannot be told apart from a persisted
+// path, see PathSeparator and RegionSeparator. Machines without nested or
+// parallel states may use any name.
+func (f *FSM) nameProblems(names []string) []ValidationProblem {
+	nested, parallel := false, false
+	for _, name := range names {
+		if f.parentOf(name) != "" {
+			nested = true
+		}
+		if f.isParallel(name) {
+			parallel = true
+		}
+	}
+
+	var problems []ValidationProblem
+	for _, name := range names {
+		if nested && strings.Contains(name, PathSeparator) {
+			problems = append(problems, ValidationProblem{
+				Kind:    InvalidName,
+				State:   name,
+				Message: fmt.Sprintf("state '%s' contains '%s', which separates nested states", name, PathSeparator),
+			})
+		}
+		if parallel && strings.Contains(name, RegionSeparator) {
+			problems = append(problems, ValidationProblem{
+				Kind:    InvalidName,
+				State:   name,
+				Message: fmt.Sprintf("state '%s' contains '%s', which separates parallel regions", name, RegionSeparator),
+			})
+		}
+	}
+	return problems
+}
+
+// isOpaque reports whether the state's transitions are decided by code that
+// cannot be inspected.
+func (f *FSM) isOpaque(state string) bool {
+	s, ok := f.declared(state)
+	if !ok {
+		return true
+	}
+	_, handles := s.impl.(State)
+	return handles
+}
+
+// rules collects the declared transitions of every definition the states
+// come from.
+func (f *FSM) rules(names []string) []TransitionRule {
+	seen := make(map[*Definition]bool)
+	var rules []TransitionRule
+	for _, name := range names {
+		d, ok := f.declared(name)
+		if !ok || seen[d.def] {
+			continue
+		}
+		seen[d.def] = true
+		rules = append(rules, d.def.rules...)
+	}
+	return rules
+}
+
+// reachable marks every state that can become active starting from the
+// initial state.
+func (f *FSM) reachable(outgoing map[string][]TransitionRule) map[string]bool {
+	reached := make(map[string]bool)
+	var pending []string
+	mark := func(state string) {
+		if !reached[state] {
+			reached[state] = true
+			pending = append(pending, state)
+		}
+	}
+	enter := func(target string) {
+		targets := []string{target}
+		if f.historyKind(target) != 0 {
+			// Any state below the parent may be restored.
+			mark(target)
+			targets = f.descendants(f.parentOf(target))
+		}
+		for _, t := range targets {
+			for _, s := range f.entrySet("", []string{t}) {
+				mark(s)
+			}
+		}
+	}
+
+	enter(f.initialState)
+	for len(pending) > 0 {
+		state := pending[0]
+		pending = pending[1:]
+		for _, r := range outgoing[state] {
+			enter(r.To)
+		}
+	}
+	return reached
+}
+
+func (f *FSM) descendants(state string) []string {
+	var states []string
+	for _, child := range f.childrenOf(state) {
+		states = append(states, child)
+		states = append(states, f.descendants(child)...)
+	}
+	return states
+}
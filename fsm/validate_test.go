@@ -0,0 +1,104 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+func problemKinds(t *testing.T, err error) map[ProblemKind][]string {
+	t.Helper()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	if !errors.Is(err, ErrInvalidDefinition) {
+		t.Errorf("expected error to match ErrInvalidDefinition")
+	}
+	kinds := make(map[ProblemKind][]string)
+	for _, p := range verr.Problems {
+		kinds[p.Kind] = append(kinds[p.Kind], p.State)
+	}
+	return kinds
+}
+
+func TestNewFSM_StrictValidation(t *testing.T) {
+	def := NewDefinition().
+		From("pending").On("start").To("running").
+		From("running").On("finish").To("completed").
+		From("orphan").On("start").To("running").
+		Final("completed")
+	states := append(def.States(), nil, &TransitioningState{name: "running"})
+
+	_, err := NewFSM(states,
+		WithStateStorage(NewFakeStorage()),
+		WithInitialState("pending"),
+		WithStrictValidation(),
+		WithLogger(&MockLogger{}),
+	)
+	kinds := problemKinds(t, err)
+
+	if got := kinds[NilState]; len(got) != 1 {
+		t.Errorf("expected one nil state, got %v", got)
+	}
+	if got := kinds[DuplicateState]; len(got) != 1 || got[0] != "running" {
+		t.Errorf("expected duplicate state running, got %v", got)
+	}
+}
+
+func TestFSM_Validate(t *testing.T) {
+	def := NewDefinition().
+		From("pending").On("start").To("running").
+		From("running").On("finish").To("completed").
+		From("running").On("fail").To("failed").
+		From("orphan").On("start").To("running").
+		Final("completed")
+	states := def.States()
+
+	// Leave out "completed" so the transition to it is undefined.
+	var subset []State
+	for _, s := range states {
+		if s.Name() != "completed" {
+			subset = append(subset, s)
+		}
+	}
+
+	f, err := NewFSM(subset, WithStateStorage(NewFakeStorage()), WithInitialState("pending"), WithLogger(&MockLogger{}))
+	if err != nil {
+		t.Fatalf("expected lenient NewFSM to succeed, got %v", err)
+	}
+
+	kinds := problemKinds(t, f.Validate())
+	if got := kinds[UndefinedTarget]; len(got) != 1 || got[0] != "running" {
+		t.Errorf("expected undefined target from running, got %v", got)
+	}
+	if got := kinds[UnreachableState]; len(got) != 1 || got[0] != "orphan" {
+		t.Errorf("expected orphan to be unreachable, got %v", got)
+	}
+	if got := kinds[DeadEndState]; len(got) != 1 || got[0] != "failed" {
+		t.Errorf("expected failed to be a dead end, got %v", got)
+	}
+}
+
+func TestFSM_ValidateHierarchy(t *testing.T) {
+	def := NewDefinition().
+		Composite("active", "idle", "running").
+		History("active.history", "active", DeepHistory).
+		Composite("running", "fast", "slow").
+		From("idle").On("start").To("running").
+		From("active").On("pause").To("paused").
+		From("paused").On("resume").To("active.history").
+		From("active").On("stop").To("stopped").
+		Final("stopped")
+
+	f, err := NewFSM(def.States(),
+		WithStateStorage(NewFakeStorage()),
+		WithInitialState("active"),
+		WithStrictValidation(),
+	)
+	if err != nil {
+		t.Fatalf("expected valid definition, got %v", err)
+	}
+	if err := f.Validate(); err != nil {
+		t.Errorf("expected no problems, got %v", err)
+	}
+}
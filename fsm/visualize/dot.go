@@ -0,0 +1,99 @@
+package visualize
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rluders/gofsm/fsm"
+)
+
+// DOT renders the definition as a Graphviz digraph. Composite and parallel
+// states become clusters; parallel clusters are drawn dashed.
+func DOT(ctx context.Context, def *fsm.Definition, opts ...Option) (string, error) {
+	o, err := newOptions(ctx, def, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph fsm {\n")
+	b.WriteString("  compound=true;\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=rounded];\n")
+
+	if o.initial != "" {
+		b.WriteString("  \"[*]\" [shape=point];\n")
+		fmt.Fprintf(&b, "  \"[*]\" -> %q%s;\n", o.initial, dotCluster(def, "lhead", o.initial))
+	}
+
+	for _, name := range topLevel(def) {
+		writeDOTState(&b, def, o, name, "  ")
+	}
+
+	for _, r := range def.Rules() {
+		attrs := []string{fmt.Sprintf("label=%q", label(r))}
+		if len(def.Children(r.From)) > 0 {
+			attrs = append(attrs, fmt.Sprintf("ltail=%q", "cluster_"+r.From))
+		}
+		if len(def.Children(r.To)) > 0 {
+			attrs = append(attrs, fmt.Sprintf("lhead=%q", "cluster_"+r.To))
+		}
+		fmt.Fprintf(&b, "  %q -> %q [%s];\n", r.From, r.To, strings.Join(attrs, ", "))
+	}
+
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+func writeDOTState(b *strings.Builder, def *fsm.Definition, o *options, name, indent string) {
+	children := def.Children(name)
+	if len(children) == 0 {
+		var attrs []string
+		if def.IsFinal(name) {
+			attrs = append(attrs, "shape=doublecircle")
+		}
+		if o.highlight[name] {
+			attrs = append(attrs, "style=\"rounded,filled\"", "fillcolor=gold")
+		}
+		if len(attrs) == 0 {
+			fmt.Fprintf(b, "%s%q;\n", indent, name)
+		} else {
+			fmt.Fprintf(b, "%s%q [%s];\n", indent, name, strings.Join(attrs, ", "))
+		}
+		return
+	}
+
+	inner := indent + "  "
+	fmt.Fprintf(b, "%ssubgraph %q {\n", indent, "cluster_"+name)
+	fmt.Fprintf(b, "%slabel=%q;\n", inner, name)
+	switch {
+	case def.IsParallel(name):
+		fmt.Fprintf(b, "%sstyle=dashed;\n", inner)
+	case o.highlight[name]:
+		fmt.Fprintf(b, "%sstyle=filled;\n%sfillcolor=lightyellow;\n", inner, inner)
+	}
+
+	// The cluster is addressed through an invisible node named after it.
+	fmt.Fprintf(b, "%s%q [shape=point, style=invis];\n", inner, name)
+	if !def.IsParallel(name) {
+		start := name + fsm.PathSeparator + "[*]"
+		fmt.Fprintf(b, "%s%q [shape=point];\n", inner, start)
+		fmt.Fprintf(b, "%s%q -> %q%s;\n", inner, start, children[0], dotCluster(def, "lhead", children[0]))
+	}
+
+	for _, h := range historyStates(def, name) {
+		fmt.Fprintf(b, "%s%q [shape=circle, label=%q];\n", inner, h, historyLabel(def.HistoryType(h)))
+	}
+	for _, child := range children {
+		writeDOTState(b, def, o, child, inner)
+	}
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+func dotCluster(def *fsm.Definition, attr, state string) string {
+	if len(def.Children(state)) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" [%s=%q]", attr, "cluster_"+state)
+}
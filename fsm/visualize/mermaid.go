@@ -0,0 +1,93 @@
+package visualize
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rluders/gofsm/fsm"
+)
+
+// Mermaid renders the definition as a Mermaid stateDiagram-v2. Regions of a
+// parallel state are separated with "--".
+func Mermaid(ctx context.Context, def *fsm.Definition, opts ...Option) (string, error) {
+	o, err := newOptions(ctx, def, opts)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := rulesByScope(def)
+
+	var b strings.Builder
+	b.WriteString("stateDiagram-v2\n")
+	if o.initial != "" {
+		fmt.Fprintf(&b, "    [*] --> %s\n", identifier(o.initial))
+	}
+	for _, name := range topLevel(def) {
+		writeMermaidState(&b, def, scopes, name, "    ")
+	}
+	writeMermaidRules(&b, def, scopes[""], "", "    ")
+
+	var current []string
+	for _, name := range def.StateNames() {
+		if o.highlight[name] {
+			current = append(current, identifier(name))
+		}
+	}
+	if len(current) > 0 {
+		b.WriteString("    classDef current fill:gold,font-weight:bold\n")
+		fmt.Fprintf(&b, "    class %s current\n", strings.Join(current, ","))
+	}
+	return b.String(), nil
+}
+
+func writeMermaidState(b *strings.Builder, def *fsm.Definition, scopes map[string][]fsm.TransitionRule, name, indent string) {
+	id := identifier(name)
+	children := def.Children(name)
+	if len(children) == 0 {
+		if id != name {
+			fmt.Fprintf(b, "%sstate %q as %s\n", indent, name, id)
+		} else {
+			fmt.Fprintf(b, "%s%s\n", indent, id)
+		}
+		return
+	}
+
+	inner := indent + "    "
+	if id != name {
+		fmt.Fprintf(b, "%sstate %q as %s {\n", indent, name, id)
+	} else {
+		fmt.Fprintf(b, "%sstate %s {\n", indent, id)
+	}
+	if def.IsParallel(name) {
+		for i, child := range children {
+			if i > 0 {
+				fmt.Fprintf(b, "%s--\n", inner)
+			}
+			writeMermaidState(b, def, scopes, child, inner)
+		}
+	} else {
+		fmt.Fprintf(b, "%s[*] --> %s\n", inner, identifier(children[0]))
+		for _, h := range historyStates(def, name) {
+			fmt.Fprintf(b, "%sstate %q as %s\n", inner, historyLabel(def.HistoryType(h)), identifier(h))
+		}
+		for _, child := range children {
+			writeMermaidState(b, def, scopes, child, inner)
+		}
+		writeMermaidRules(b, def, scopes[name], name, inner)
+	}
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+// writeMermaidRules writes the transitions of a scope and the end markers
+// of its final states.
+func writeMermaidRules(b *strings.Builder, def *fsm.Definition, rules []fsm.TransitionRule, scope, indent string) {
+	for _, r := range rules {
+		fmt.Fprintf(b, "%s%s --> %s : %s\n", indent, identifier(r.From), identifier(r.To), label(r))
+	}
+	for _, name := range def.StateNames() {
+		if def.IsFinal(name) && def.Parent(name) == scope {
+			fmt.Fprintf(b, "%s%s --> [*]\n", indent, identifier(name))
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package visualize
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rluders/gofsm/fsm"
+)
+
+// PlantUML renders the definition as a PlantUML state diagram. Transitions
+// to history pseudo-states use the parent[H] and parent[H*] notation.
+func PlantUML(ctx context.Context, def *fsm.Definition, opts ...Option) (string, error) {
+	o, err := newOptions(ctx, def, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("@startuml\n")
+	if o.initial != "" {
+		fmt.Fprintf(&b, "[*] --> %s\n", identifier(o.initial))
+	}
+	for _, name := range topLevel(def) {
+		writePlantUMLState(&b, def, o, name, "")
+	}
+
+	for _, r := range def.Rules() {
+		to := identifier(r.To)
+		if kind := def.HistoryType(r.To); kind != 0 {
+			to = identifier(def.Parent(r.To)) + "[" + historyLabel(kind) + "]"
+		}
+		fmt.Fprintf(&b, "%s --> %s : %s\n", identifier(r.From), to, label(r))
+	}
+	for _, name := range def.StateNames() {
+		if def.IsFinal(name) && def.Parent(name) == "" {
+			fmt.Fprintf(&b, "%s --> [*]\n", identifier(name))
+		}
+	}
+
+	b.WriteString("@enduml\n")
+	return b.String(), nil
+}
+
+func writePlantUMLState(b *strings.Builder, def *fsm.Definition, o *options, name, indent string) {
+	decl := "state " + identifier(name)
+	if identifier(name) != name {
+		decl = fmt.Sprintf("state %q as %s", name, identifier(name))
+	}
+	if o.highlight[name] {
+		decl += " #gold"
+	}
+
+	children := def.Children(name)
+	if len(children) == 0 {
+		fmt.Fprintf(b, "%s%s\n", indent, decl)
+		return
+	}
+
+	inner := indent + "  "
+	fmt.Fprintf(b, "%s%s {\n", indent, decl)
+	if def.IsParallel(name) {
+		for i, child := range children {
+			if i > 0 {
+				fmt.Fprintf(b, "%s--\n", inner)
+			}
+			writePlantUMLState(b, def, o, child, inner)
+		}
+	} else {
+		fmt.Fprintf(b, "%s[*] --> %s\n", inner, identifier(children[0]))
+		for _, child := range children {
+			writePlantUMLState(b, def, o, child, inner)
+		}
+		for _, child := range children {
+			if def.IsFinal(child) {
+				fmt.Fprintf(b, "%s%s --> [*]\n", inner, identifier(child))
+			}
+		}
+	}
+	fmt.Fprintf(b, "%s}\n", indent)
+}
@@ -0,0 +1,156 @@
+// Package visualize renders fsm definitions as Graphviz DOT, Mermaid and
+// PlantUML diagrams.
+package visualize
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rluders/gofsm/fsm"
+)
+
+type Option func(*options)
+
+type options struct {
+	initial   string
+	highlight map[string]bool
+	storage   fsm.StateStorage
+	entityID  string
+}
+
+// WithInitialState draws the start marker pointing at state.
+func WithInitialState(state string) Option {
+	return func(o *options) {
+		o.initial = state
+	}
+}
+
+// WithHighlight highlights the given states.
+func WithHighlight(states ...string) Option {
+	return func(o *options) {
+		for _, s := range states {
+			o.highlight[s] = true
+		}
+	}
+}
+
+// WithEntity highlights the states an entity is currently in, as read from
+// storage when the diagram is rendered.
+func WithEntity(storage fsm.StateStorage, entityID string) Option {
+	return func(o *options) {
+		o.storage = storage
+		o.entityID = entityID
+	}
+}
+
+func newOptions(ctx context.Context, def *fsm.Definition, opts []Option) (*options, error) {
+	o := &options{highlight: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.storage != nil {
+		value, err := o.storage.GetState(ctx, o.entityID)
+		if err != nil {
+			return nil, err
+		}
+		for _, leaf := range activeLeaves(def, value) {
+			o.highlight[leaf] = true
+		}
+	}
+	return o, nil
+}
+
+// activeLeaves extracts the leaf state names from a persisted state value.
+// Declared names are kept whole, like the FSM does for flat machines.
+func activeLeaves(def *fsm.Definition, value string) []string {
+	declared := make(map[string]bool)
+	for _, name := range def.StateNames() {
+		declared[name] = true
+	}
+
+	if declared[value] {
+		return []string{value}
+	}
+
+	var leaves []string
+	for _, path := range strings.Split(value, fsm.RegionSeparator) {
+		if declared[path] {
+			leaves = append(leaves, path)
+			continue
+		}
+		if i := strings.LastIndex(path, fsm.PathSeparator); i >= 0 {
+			path = path[i+len(fsm.PathSeparator):]
+		}
+		leaves = append(leaves, path)
+	}
+	return leaves
+}
+
+// topLevel returns the states without a parent, in declaration order.
+func topLevel(def *fsm.Definition) []string {
+	var states []string
+	for _, name := range def.StateNames() {
+		if def.Parent(name) == "" {
+			states = append(states, name)
+		}
+	}
+	return states
+}
+
+// historyStates returns the history pseudo-states of parent, which are not
+// among its children.
+func historyStates(def *fsm.Definition, parent string) []string {
+	var states []string
+	for _, name := range def.StateNames() {
+		if def.HistoryType(name) != 0 && def.Parent(name) == parent {
+			states = append(states, name)
+		}
+	}
+	return states
+}
+
+// rulesByScope groups the rules by the composite state that contains both
+// ends, "" meaning the top level. Parallel states are not scopes.
+func rulesByScope(def *fsm.Definition) map[string][]fsm.TransitionRule {
+	scopes := make(map[string][]fsm.TransitionRule)
+	for _, r := range def.Rules() {
+		scope := ""
+		if parent := def.Parent(r.From); parent != "" && parent == def.Parent(r.To) && !def.IsParallel(parent) {
+			scope = parent
+		}
+		scopes[scope] = append(scopes[scope], r)
+	}
+	return scopes
+}
+
+func label(r fsm.TransitionRule) string {
+	switch {
+	case r.GuardName != "":
+		return r.Event + " [" + r.GuardName + "]"
+	case r.Guard != nil:
+		return r.Event + " [guard]"
+	}
+	return r.Event
+}
+
+func historyLabel(kind fsm.HistoryKind) string {
+	if kind == fsm.DeepHistory {
+		return "H*"
+	}
+	return "H"
+}
+
+// identifier turns a state name into an identifier accepted by Mermaid and
+// PlantUML.
+func identifier(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
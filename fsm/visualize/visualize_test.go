@@ -0,0 +1,113 @@
+package visualize
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rluders/gofsm/fsm"
+	"github.com/rluders/gofsm/storage/memory"
+)
+
+func allow(ctx context.Context, entityID string, event fsm.Event) (bool, error) {
+	return true, nil
+}
+
+func scanDefinition() *fsm.Definition {
+	return fsm.NewDefinition().
+		Composite("active", "pending", "running").
+		History("active.history", "active", fsm.DeepHistory).
+		Parallel("running", "jobs", "notify").
+		Composite("jobs", "working", "done").
+		Composite("notify", "unsent", "sent").
+		Final("done", "sent", "completed").
+		From("pending").On("start_scan").GuardNamed("hasTargets", allow).To("running").
+		From("working").On("all_jobs_completed").To("done").
+		From("unsent").On("notified").Guard(allow).To("sent").
+		From("active").On("pause").To("paused").
+		From("paused").On("resume").To("active.history").
+		From("running").OnDone().To("completed")
+}
+
+func assertContains(t *testing.T, out string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("expected output to contain %q, got:\n%s", w, out)
+		}
+	}
+}
+
+func TestDOT(t *testing.T) {
+	out, err := DOT(context.Background(), scanDefinition(), WithInitialState("active"), WithHighlight("working"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertContains(t, out,
+		"digraph fsm {",
+		`subgraph "cluster_active" {`,
+		`subgraph "cluster_running" {`,
+		"style=dashed;",
+		`"completed" [shape=doublecircle];`,
+		`"working" [style="rounded,filled", fillcolor=gold];`,
+		`"active.history" [shape=circle, label="H*"];`,
+		`"pending" -> "running" [label="start_scan [hasTargets]", lhead="cluster_running"];`,
+		`"unsent" -> "sent" [label="notified [guard]"];`,
+		`"[*]" -> "active" [lhead="cluster_active"];`,
+	)
+}
+
+func TestMermaid(t *testing.T) {
+	out, err := Mermaid(context.Background(), scanDefinition(), WithInitialState("active"), WithHighlight("working", "unsent"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertContains(t, out,
+		"stateDiagram-v2\n",
+		"    [*] --> active\n",
+		"    state active {\n        [*] --> pending\n",
+		"            state jobs {\n",
+		"            --\n",
+		`state "H*" as active_history`,
+		"        pending --> running : start_scan [hasTargets]\n",
+		"    paused --> active_history : resume\n",
+		"    completed --> [*]\n",
+		"class working,unsent current",
+	)
+}
+
+func TestPlantUML(t *testing.T) {
+	out, err := PlantUML(context.Background(), scanDefinition())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertContains(t, out,
+		"@startuml\n",
+		"state active {\n  [*] --> pending\n",
+		"    --\n",
+		"paused --> active[H*] : resume\n",
+		"running --> completed : done.state.running\n",
+		"      done --> [*]\n",
+		"completed --> [*]\n",
+		"@enduml\n",
+	)
+}
+
+func TestWithEntity(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewMemoryStorage()
+	storage.SetState(ctx, "scan-1", "active/running/jobs/working,active/running/notify/sent")
+
+	out, err := PlantUML(ctx, scanDefinition(), WithEntity(storage, "scan-1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertContains(t, out, "state working #gold\n", "state sent #gold\n")
+	if strings.Contains(out, "state unsent #gold") {
+		t.Errorf("expected unsent not to be highlighted:\n%s", out)
+	}
+
+	if _, err := DOT(ctx, scanDefinition(), WithEntity(storage, "missing")); err == nil {
+		t.Error("expected error for unknown entity")
+	}
+}
@@ -2,6 +2,7 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"log"
 	"time"
 
@@ -54,9 +55,15 @@ func (c *Consumer) Start(ctx context.Context) error {
 
 		ctxWithID := context.WithValue(ctx, "scanID", entityID)
 
-		if err := c.fsmEngine.Trigger(ctxWithID, entityID, event); err != nil {
+		result, err := c.fsmEngine.TriggerWithResult(ctxWithID, entityID, event)
+		switch {
+		case errors.Is(err, fsm.ErrGuardRejected), errors.Is(err, fsm.ErrEntityCompleted):
+			log.Printf("Kafka message rejected: %v", err)
+		case err != nil:
 			log.Printf("Error when scanning Kafka message: %v", err)
 			continue
+		case result.Changed:
+			log.Printf("Entity %s moved from %s to %s in %s", entityID, result.From, result.To, result.Duration)
 		}
 
 		if err := c.reader.CommitMessages(ctx, m); err != nil {
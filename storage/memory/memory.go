@@ -3,29 +3,57 @@ package memory
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
+
+	"github.com/rluders/gofsm/fsm"
 )
 
 type MemoryStorage struct {
-	states map[string]string
-	locks  map[string]*sync.Mutex
-	mu     sync.RWMutex
+	states   map[string]string
+	archived map[string]string
+	locks    map[string]*sync.Mutex
+	timers   map[timerKey]fsm.Timer
+	history  map[string][]fsm.TransitionRecord
+	data     map[string][]byte
+	versions map[string]int64
+	remember map[string]map[string]string
+	mu       sync.RWMutex
+}
+
+type timerKey struct {
+	entityID string
+	state    string
 }
 
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		states: make(map[string]string),
-		locks:  make(map[string]*sync.Mutex),
+		states:   make(map[string]string),
+		archived: make(map[string]string),
+		locks:    make(map[string]*sync.Mutex),
+		timers:   make(map[timerKey]fsm.Timer),
+		history:  make(map[string][]fsm.TransitionRecord),
+		data:     make(map[string][]byte),
+		versions: make(map[string]int64),
+		remember: make(map[string]map[string]string),
 	}
 }
 
 func (m *MemoryStorage) GetState(ctx context.Context, entityID string) (string, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	return m.getState(entityID)
+}
+
+func (m *MemoryStorage) getState(entityID string) (string, error) {
 	state, ok := m.states[entityID]
 	if !ok {
-		return "", errors.New("state not found")
+		state, ok = m.archived[entityID]
+	}
+	if !ok {
+		return "", fmt.Errorf("%w: '%s'", fsm.ErrEntityNotFound, entityID)
 	}
 	return state, nil
 }
@@ -33,10 +61,127 @@ func (m *MemoryStorage) GetState(ctx context.Context, entityID string) (string,
 func (m *MemoryStorage) SetState(ctx context.Context, entityID string, state string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.setState(entityID, state)
+	return nil
+}
+
+// setState must be called with the write lock held. Every write bumps the
+// version, see fsm.VersionedStorage.
+func (m *MemoryStorage) setState(entityID, state string) int64 {
 	m.states[entityID] = state
+	delete(m.archived, entityID)
+	m.versions[entityID]++
+	return m.versions[entityID]
+}
+
+func (m *MemoryStorage) GetStateData(ctx context.Context, entityID string) (string, []byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	state, err := m.getState(entityID)
+	if err != nil {
+		return "", nil, err
+	}
+	return state, append([]byte(nil), m.data[entityID]...), nil
+}
+
+func (m *MemoryStorage) SetStateData(ctx context.Context, entityID, state string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setState(entityID, state)
+	m.data[entityID] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m *MemoryStorage) GetStateVersion(ctx context.Context, entityID string) (string, int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	state, err := m.getState(entityID)
+	if err != nil {
+		return "", 0, err
+	}
+	return state, m.versions[entityID], nil
+}
+
+func (m *MemoryStorage) CompareAndSetState(ctx context.Context, entityID, state string, version int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkVersion(entityID, version); err != nil {
+		return 0, err
+	}
+	return m.setState(entityID, state), nil
+}
+
+func (m *MemoryStorage) GetStateDataVersion(ctx context.Context, entityID string) (string, []byte, int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	state, err := m.getState(entityID)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	return state, append([]byte(nil), m.data[entityID]...), m.versions[entityID], nil
+}
+
+func (m *MemoryStorage) CompareAndSetStateData(ctx context.Context, entityID, state string, data []byte, version int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkVersion(entityID, version); err != nil {
+		return 0, err
+	}
+	m.data[entityID] = append([]byte(nil), data...)
+	return m.setState(entityID, state), nil
+}
+
+func (m *MemoryStorage) checkVersion(entityID string, version int64) error {
+	if current := m.versions[entityID]; current != version {
+		return fmt.Errorf("%w: '%s' is at version %d, not %d", fsm.ErrVersionConflict, entityID, current, version)
+	}
+	return nil
+}
+
+func (m *MemoryStorage) GetHistoryStates(ctx context.Context, entityID string) (map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	states := make(map[string]string, len(m.remember[entityID]))
+	for parent, value := range m.remember[entityID] {
+		states[parent] = value
+	}
+	return states, nil
+}
+
+func (m *MemoryStorage) SetHistoryStates(ctx context.Context, entityID string, states map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.remember[entityID] == nil {
+		m.remember[entityID] = make(map[string]string)
+	}
+	for parent, value := range states {
+		m.remember[entityID][parent] = value
+	}
 	return nil
 }
 
+// Complete moves an entity that reached a final state to the archive. It can
+// still be read with GetState; its history states are dropped.
+func (m *MemoryStorage) Complete(ctx context.Context, entityID, state string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.states, entityID)
+	delete(m.remember, entityID)
+	m.archived[entityID] = state
+	return nil
+}
+
+// Archived returns a snapshot of the completed entities and their final state.
+func (m *MemoryStorage) Archived() map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	archived := make(map[string]string, len(m.archived))
+	for id, state := range m.archived {
+		archived[id] = state
+	}
+	return archived
+}
+
 func (m *MemoryStorage) Lock(ctx context.Context, entityID string) (func(), error) {
 	m.mu.Lock()
 	lock, ok := m.locks[entityID]
@@ -51,3 +196,47 @@ func (m *MemoryStorage) Lock(ctx context.Context, entityID string) (func(), erro
 		lock.Unlock()
 	}, nil
 }
+
+func (m *MemoryStorage) Schedule(ctx context.Context, timer fsm.Timer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timers[timerKey{timer.EntityID, timer.State}] = timer
+	return nil
+}
+
+func (m *MemoryStorage) Cancel(ctx context.Context, timer fsm.Timer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.timers, timerKey{timer.EntityID, timer.State})
+	return nil
+}
+
+func (m *MemoryStorage) Due(ctx context.Context, now time.Time) ([]fsm.Timer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []fsm.Timer
+	for key, timer := range m.timers {
+		if !timer.FireAt.After(now) {
+			due = append(due, timer)
+			delete(m.timers, key)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].FireAt.Before(due[j].FireAt)
+	})
+	return due, nil
+}
+
+func (m *MemoryStorage) Append(ctx context.Context, record fsm.TransitionRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.history[record.EntityID] = append(m.history[record.EntityID], record)
+	return nil
+}
+
+func (m *MemoryStorage) History(ctx context.Context, entityID string) ([]fsm.TransitionRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]fsm.TransitionRecord(nil), m.history[entityID]...), nil
+}
@@ -0,0 +1,149 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rluders/gofsm/fsm"
+)
+
+func TestMemoryStorage_Complete(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	storage.SetState(ctx, "entity-1", "running")
+	storage.SetHistoryStates(ctx, "entity-1", map[string]string{"scan": "scan/running"})
+
+	if err := storage.Complete(ctx, "entity-1", "done"); err != nil {
+		t.Fatalf("failed to complete: %v", err)
+	}
+	if state, err := storage.GetState(ctx, "entity-1"); err != nil || state != "done" {
+		t.Errorf("expected archived state to be readable, got %s: %v", state, err)
+	}
+	if archived := storage.Archived(); archived["entity-1"] != "done" {
+		t.Errorf("expected entity to be archived, got %v", archived)
+	}
+	if states, _ := storage.GetHistoryStates(ctx, "entity-1"); len(states) != 0 {
+		t.Errorf("expected history states to be dropped, got %v", states)
+	}
+
+	storage.SetState(ctx, "entity-1", "running")
+	if archived := storage.Archived(); len(archived) != 0 {
+		t.Errorf("expected SetState to unarchive the entity, got %v", archived)
+	}
+}
+
+func TestMemoryStorage_StateData(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+
+	if _, _, err := storage.GetStateData(ctx, "entity-1"); !errors.Is(err, fsm.ErrEntityNotFound) {
+		t.Fatalf("expected ErrEntityNotFound, got %v", err)
+	}
+	data := []byte(`{"jobs":2}`)
+	if err := storage.SetStateData(ctx, "entity-1", "running", data); err != nil {
+		t.Fatalf("failed to set state data: %v", err)
+	}
+	data[0] = 'x'
+
+	state, stored, err := storage.GetStateData(ctx, "entity-1")
+	if err != nil || state != "running" || string(stored) != `{"jobs":2}` {
+		t.Errorf("unexpected state data: %s %s %v", state, stored, err)
+	}
+
+	storage.SetState(ctx, "entity-1", "completed")
+	if _, stored, _ := storage.GetStateData(ctx, "entity-1"); string(stored) != `{"jobs":2}` {
+		t.Errorf("expected SetState to keep the data, got %s", stored)
+	}
+}
+
+func TestMemoryStorage_CompareAndSetState(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+
+	version, err := storage.CompareAndSetState(ctx, "entity-1", "pending", 0)
+	if err != nil || version != 1 {
+		t.Fatalf("failed to create entity: %d, %v", version, err)
+	}
+	if _, err := storage.CompareAndSetState(ctx, "entity-1", "pending", 0); !errors.Is(err, fsm.ErrVersionConflict) {
+		t.Errorf("expected ErrVersionConflict creating an existing entity, got %v", err)
+	}
+
+	storage.SetState(ctx, "entity-1", "pending")
+	if _, err := storage.CompareAndSetState(ctx, "entity-1", "running", version); !errors.Is(err, fsm.ErrVersionConflict) {
+		t.Errorf("expected ErrVersionConflict after SetState, got %v", err)
+	}
+
+	state, version, err := storage.GetStateVersion(ctx, "entity-1")
+	if err != nil || state != "pending" || version != 2 {
+		t.Fatalf("unexpected state %s at version %d: %v", state, version, err)
+	}
+	next, err := storage.CompareAndSetStateData(ctx, "entity-1", "running", []byte(`{"jobs":2}`), version)
+	if err != nil || next != 3 {
+		t.Fatalf("failed to compare and set: %d, %v", next, err)
+	}
+	if _, err := storage.CompareAndSetStateData(ctx, "entity-1", "failed", nil, version); !errors.Is(err, fsm.ErrVersionConflict) {
+		t.Errorf("expected ErrVersionConflict with a stale version, got %v", err)
+	}
+
+	state, data, version, err := storage.GetStateDataVersion(ctx, "entity-1")
+	if err != nil || state != "running" || string(data) != `{"jobs":2}` || version != 3 {
+		t.Errorf("unexpected state data: %s %s %d %v", state, data, version, err)
+	}
+}
+
+func TestMemoryStorage_HistoryStates(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+
+	storage.SetHistoryStates(ctx, "entity-1", map[string]string{"active": "active/running"})
+	storage.SetHistoryStates(ctx, "entity-1", map[string]string{"scan": "scan/done"})
+
+	states, err := storage.GetHistoryStates(ctx, "entity-1")
+	if err != nil || len(states) != 2 || states["active"] != "active/running" || states["scan"] != "scan/done" {
+		t.Errorf("unexpected history states: %v, %v", states, err)
+	}
+	if _, err := storage.GetState(ctx, "entity-1"); !errors.Is(err, fsm.ErrEntityNotFound) {
+		t.Errorf("expected history states not to create the entity, got %v", err)
+	}
+}
+
+func TestMemoryStorage_Timers(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	now := time.Now()
+
+	storage.Schedule(ctx, fsm.Timer{EntityID: "entity-1", State: "running", Event: "timeout", FireAt: now.Add(time.Minute)})
+	storage.Schedule(ctx, fsm.Timer{EntityID: "entity-2", State: "running", Event: "timeout", FireAt: now.Add(-time.Second)})
+	storage.Schedule(ctx, fsm.Timer{EntityID: "entity-3", State: "running", Event: "timeout", FireAt: now.Add(-time.Minute)})
+	storage.Cancel(ctx, fsm.Timer{EntityID: "entity-2", State: "running"})
+
+	due, err := storage.Due(ctx, now)
+	if err != nil {
+		t.Fatalf("failed to read due timers: %v", err)
+	}
+	if len(due) != 1 || due[0].EntityID != "entity-3" {
+		t.Fatalf("expected only entity-3 to be due, got %+v", due)
+	}
+	if due, _ := storage.Due(ctx, now); len(due) != 0 {
+		t.Errorf("expected due timers to be removed, got %+v", due)
+	}
+	if due, _ := storage.Due(ctx, now.Add(2*time.Minute)); len(due) != 1 || due[0].EntityID != "entity-1" {
+		t.Errorf("expected entity-1 to be due later, got %+v", due)
+	}
+}
+
+func TestMemoryStorage_History(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+
+	storage.Append(ctx, fsm.TransitionRecord{EntityID: "entity-1", Event: fsm.InitEvent, To: "pending"})
+	storage.Append(ctx, fsm.TransitionRecord{EntityID: "entity-1", Event: "start", From: "pending", To: "running"})
+	storage.Append(ctx, fsm.TransitionRecord{EntityID: "entity-2", Event: fsm.InitEvent, To: "pending"})
+
+	records, err := storage.History(ctx, "entity-1")
+	if err != nil || len(records) != 2 || records[1].To != "running" {
+		t.Errorf("unexpected records: %+v, %v", records, err)
+	}
+}
@@ -2,18 +2,22 @@ package redis
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/rluders/gofsm/fsm"
 )
 
 type RedisStorage struct {
-	client  *redis.Client
-	prefix  string
-	ttl     time.Duration
-	lockTTL time.Duration
+	client       *redis.Client
+	prefix       string
+	ttl          time.Duration
+	lockTTL      time.Duration
+	completedTTL time.Duration
 }
 
 type Option func(*RedisStorage)
@@ -36,6 +40,14 @@ func WithLockTTL(ttl time.Duration) Option {
 	}
 }
 
+// WithCompletedTTL expires entities the given time after they reach a final
+// state. Zero keeps them according to WithTTL.
+func WithCompletedTTL(ttl time.Duration) Option {
+	return func(r *RedisStorage) {
+		r.completedTTL = ttl
+	}
+}
+
 func NewRedisStorage(client *redis.Client, opts ...Option) *RedisStorage {
 	r := &RedisStorage{
 		client:  client,
@@ -49,32 +61,264 @@ func NewRedisStorage(client *redis.Client, opts ...Option) *RedisStorage {
 	return r
 }
 
+// key is where the entity's state is stored. States have their own
+// namespace so that no entity ID can collide with the keys below.
 func (r *RedisStorage) key(id string) string {
-	return fmt.Sprintf("%s:%s", r.prefix, id)
+	return fmt.Sprintf("%s:state:%s", r.prefix, id)
+}
+
+// legacyKey is where states were stored before they got their own
+// namespace. It is read when key is missing and removed on the next write.
+// IDs whose legacy key may be one of the keys below have none.
+func (r *RedisStorage) legacyKey(id string) (string, bool) {
+	if id == "timers" {
+		return "", false
+	}
+	if namespace, _, ok := strings.Cut(id, ":"); ok {
+		switch namespace {
+		case "state", "data", "history", "version", "lock", "transitions":
+			return "", false
+		}
+	}
+	return fmt.Sprintf("%s:%s", r.prefix, id), true
+}
+
+func (r *RedisStorage) dropLegacyKey(ctx context.Context, pipe redis.Pipeliner, id string) {
+	if key, ok := r.legacyKey(id); ok {
+		pipe.Del(ctx, key)
+	}
+}
+
+func (r *RedisStorage) dataKey(id string) string {
+	return fmt.Sprintf("%s:data:%s", r.prefix, id)
+}
+
+func (r *RedisStorage) historyStatesKey(id string) string {
+	return fmt.Sprintf("%s:history:%s", r.prefix, id)
+}
+
+func (r *RedisStorage) versionKey(id string) string {
+	return fmt.Sprintf("%s:version:%s", r.prefix, id)
 }
 
 func (r *RedisStorage) lockKey(id string) string {
 	return fmt.Sprintf("%s:lock:%s", r.prefix, id)
 }
 
+func (r *RedisStorage) historyKey(id string) string {
+	return fmt.Sprintf("%s:transitions:%s", r.prefix, id)
+}
+
+func (r *RedisStorage) timersKey() string {
+	return fmt.Sprintf("%s:timers", r.prefix)
+}
+
 func (r *RedisStorage) GetState(ctx context.Context, entityID string) (string, error) {
-	key := r.key(entityID)
-	val, err := r.client.Get(ctx, key).Result()
-	if errors.Is(err, redis.Nil) {
-		return "", fmt.Errorf("redis: state not found for ID '%s'", entityID)
+	state, _, err := r.readState(ctx, entityID)
+	return state, err
+}
+
+// readState reads the state, falling back to its legacy key, together with
+// the given keys in a single round trip. It returns the values of keys.
+func (r *RedisStorage) readState(ctx context.Context, entityID string, keys ...string) (string, []any, error) {
+	all := append([]string{r.key(entityID)}, keys...)
+	legacy, hasLegacy := r.legacyKey(entityID)
+	if hasLegacy {
+		all = append(all, legacy)
 	}
+	values, err := r.client.MGet(ctx, all...).Result()
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+	state, ok := values[0].(string)
+	if !ok && hasLegacy {
+		state, ok = values[len(values)-1].(string)
 	}
-	return val, nil
+	if !ok {
+		return "", nil, fmt.Errorf("redis: %w: '%s'", fsm.ErrEntityNotFound, entityID)
+	}
+	return state, values[1 : len(keys)+1], nil
 }
 
+// SetState writes the state and bumps its version, so that concurrent
+// CompareAndSetState calls notice the change.
 func (r *RedisStorage) SetState(ctx context.Context, entityID, state string) error {
-	key := r.key(entityID)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.key(entityID), state, r.ttl)
+		r.dropLegacyKey(ctx, pipe, entityID)
+		r.bumpVersion(ctx, pipe, entityID)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisStorage) bumpVersion(ctx context.Context, pipe redis.Pipeliner, entityID string) {
+	pipe.Incr(ctx, r.versionKey(entityID))
 	if r.ttl > 0 {
-		return r.client.Set(ctx, key, state, r.ttl).Err()
+		pipe.Expire(ctx, r.versionKey(entityID), r.ttl)
+	}
+}
+
+// GetStateData reads the state and the extended state, which is stored in
+// its own key, in a single round trip.
+func (r *RedisStorage) GetStateData(ctx context.Context, entityID string) (string, []byte, error) {
+	state, values, err := r.readState(ctx, entityID, r.dataKey(entityID))
+	if err != nil {
+		return "", nil, err
+	}
+	var data []byte
+	if v, ok := values[0].(string); ok {
+		data = []byte(v)
+	}
+	return state, data, nil
+}
+
+// SetStateData writes the state and the extended state in a MULTI/EXEC
+// transaction.
+func (r *RedisStorage) SetStateData(ctx context.Context, entityID, state string, data []byte) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.key(entityID), state, r.ttl)
+		r.dropLegacyKey(ctx, pipe, entityID)
+		if data == nil {
+			pipe.Del(ctx, r.dataKey(entityID))
+		} else {
+			pipe.Set(ctx, r.dataKey(entityID), data, r.ttl)
+		}
+		r.bumpVersion(ctx, pipe, entityID)
+		return nil
+	})
+	return err
+}
+
+// GetStateVersion reads the state and its version. Entities written before
+// versions were stored are at version 0.
+func (r *RedisStorage) GetStateVersion(ctx context.Context, entityID string) (string, int64, error) {
+	state, _, version, err := r.getStateDataVersion(ctx, entityID, false)
+	return state, version, err
+}
+
+func (r *RedisStorage) GetStateDataVersion(ctx context.Context, entityID string) (string, []byte, int64, error) {
+	return r.getStateDataVersion(ctx, entityID, true)
+}
+
+func (r *RedisStorage) getStateDataVersion(ctx context.Context, entityID string, withData bool) (string, []byte, int64, error) {
+	keys := []string{r.versionKey(entityID)}
+	if withData {
+		keys = append(keys, r.dataKey(entityID))
+	}
+	state, values, err := r.readState(ctx, entityID, keys...)
+	if err != nil {
+		return "", nil, 0, err
 	}
-	return r.client.Set(ctx, key, state, 0).Err()
+	var version int64
+	if v, ok := values[0].(string); ok {
+		if version, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return "", nil, 0, fmt.Errorf("redis: version of '%s': %w", entityID, err)
+		}
+	}
+	var data []byte
+	if withData {
+		if v, ok := values[1].(string); ok {
+			data = []byte(v)
+		}
+	}
+	return state, data, version, nil
+}
+
+// compareAndSet writes the state, and the data unless ARGV[4] is "keep",
+// only if the version matches. A state found in neither its key nor the
+// optional legacy key KEYS[4] is at version 0, even if a version key
+// outlived it. It returns the new version, or -1 on conflict.
+var compareAndSet = redis.NewScript(`
+local current = 0
+if redis.call('EXISTS', KEYS[1]) == 1 or (KEYS[4] and redis.call('EXISTS', KEYS[4]) == 1) then
+	current = tonumber(redis.call('GET', KEYS[2]) or '0')
+end
+if current ~= tonumber(ARGV[2]) then
+	return -1
+end
+
+local ttl = tonumber(ARGV[3])
+local function set(key, value)
+	if ttl > 0 then
+		redis.call('SET', key, value, 'PX', ttl)
+	else
+		redis.call('SET', key, value)
+	end
+end
+
+set(KEYS[1], ARGV[1])
+if KEYS[4] then
+	redis.call('DEL', KEYS[4])
+end
+if ARGV[4] == 'set' then
+	set(KEYS[3], ARGV[5])
+elseif ARGV[4] == 'del' then
+	redis.call('DEL', KEYS[3])
+end
+local version = redis.call('INCR', KEYS[2])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[2], ttl)
+end
+return version
+`)
+
+func (r *RedisStorage) CompareAndSetState(ctx context.Context, entityID, state string, version int64) (int64, error) {
+	return r.compareAndSet(ctx, entityID, state, version, "keep", nil)
+}
+
+func (r *RedisStorage) CompareAndSetStateData(ctx context.Context, entityID, state string, data []byte, version int64) (int64, error) {
+	if data == nil {
+		return r.compareAndSet(ctx, entityID, state, version, "del", nil)
+	}
+	return r.compareAndSet(ctx, entityID, state, version, "set", data)
+}
+
+func (r *RedisStorage) compareAndSet(ctx context.Context, entityID, state string, version int64, mode string, data []byte) (int64, error) {
+	keys := []string{r.key(entityID), r.versionKey(entityID), r.dataKey(entityID)}
+	if legacy, ok := r.legacyKey(entityID); ok {
+		keys = append(keys, legacy)
+	}
+	next, err := compareAndSet.Run(ctx, r.client, keys, state, version, r.ttl.Milliseconds(), mode, data).Int64()
+	if err != nil {
+		return 0, err
+	}
+	if next < 0 {
+		return 0, fmt.Errorf("redis: %w: '%s' is no longer at version %d", fsm.ErrVersionConflict, entityID, version)
+	}
+	return next, nil
+}
+
+// GetHistoryStates reads the configurations remembered for the entity's
+// history pseudo-states, stored in a hash next to the state.
+func (r *RedisStorage) GetHistoryStates(ctx context.Context, entityID string) (map[string]string, error) {
+	return r.client.HGetAll(ctx, r.historyStatesKey(entityID)).Result()
+}
+
+func (r *RedisStorage) SetHistoryStates(ctx context.Context, entityID string, states map[string]string) error {
+	key := r.historyStatesKey(entityID)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, states)
+		if r.ttl > 0 {
+			pipe.Expire(ctx, key, r.ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *RedisStorage) Complete(ctx context.Context, entityID, state string) error {
+	if r.completedTTL <= 0 {
+		return nil
+	}
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Expire(ctx, r.key(entityID), r.completedTTL)
+		pipe.Expire(ctx, r.dataKey(entityID), r.completedTTL)
+		pipe.Expire(ctx, r.versionKey(entityID), r.completedTTL)
+		pipe.Expire(ctx, r.historyStatesKey(entityID), r.completedTTL)
+		return nil
+	})
+	return err
 }
 
 func (r *RedisStorage) Lock(ctx context.Context, entityID string) (func(), error) {
@@ -84,7 +328,7 @@ func (r *RedisStorage) Lock(ctx context.Context, entityID string) (func(), error
 		return nil, err
 	}
 	if !ok {
-		return nil, errors.New("fsm: unable to acquire lock")
+		return nil, fsm.ErrLockNotAcquired
 	}
 
 	unlock := func() {
@@ -93,3 +337,143 @@ func (r *RedisStorage) Lock(ctx context.Context, entityID string) (func(), error
 
 	return unlock, nil
 }
+
+// timerMember identifies a timer in the sorted set; the fire time is the
+// score.
+type timerMember struct {
+	EntityID string `json:"entity_id"`
+	State    string `json:"state"`
+	Event    string `json:"event"`
+}
+
+func encodeTimer(timer fsm.Timer) (string, error) {
+	b, err := json.Marshal(timerMember{EntityID: timer.EntityID, State: timer.State, Event: timer.Event})
+	return string(b), err
+}
+
+func (r *RedisStorage) Schedule(ctx context.Context, timer fsm.Timer) error {
+	member, err := encodeTimer(timer)
+	if err != nil {
+		return err
+	}
+	return r.client.ZAdd(ctx, r.timersKey(), redis.Z{
+		Score:  float64(timer.FireAt.UnixMilli()),
+		Member: member,
+	}).Err()
+}
+
+func (r *RedisStorage) Cancel(ctx context.Context, timer fsm.Timer) error {
+	member, err := encodeTimer(timer)
+	if err != nil {
+		return err
+	}
+	return r.client.ZRem(ctx, r.timersKey(), member).Err()
+}
+
+// Due claims the timers due at now. A timer is only returned to the caller
+// that removes it from the sorted set, so concurrent pollers never deliver
+// the same timer twice.
+func (r *RedisStorage) Due(ctx context.Context, now time.Time) ([]fsm.Timer, error) {
+	members, err := r.client.ZRangeByScoreWithScores(ctx, r.timersKey(), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var due []fsm.Timer
+	for _, z := range members {
+		member, _ := z.Member.(string)
+		removed, err := r.client.ZRem(ctx, r.timersKey(), member).Result()
+		if err != nil {
+			return due, err
+		}
+		if removed == 0 {
+			continue
+		}
+
+		var tm timerMember
+		if err := json.Unmarshal([]byte(member), &tm); err != nil {
+			return due, err
+		}
+		due = append(due, fsm.Timer{
+			EntityID: tm.EntityID,
+			State:    tm.State,
+			Event:    tm.Event,
+			FireAt:   time.UnixMilli(int64(z.Score)),
+		})
+	}
+	return due, nil
+}
+
+// transitionEntry is a TransitionRecord as stored in the entity's list. The
+// payload is kept as JSON and comes back as generic JSON values.
+type transitionEntry struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Actor     string          `json:"actor,omitempty"`
+}
+
+// Append adds the record to a list per entity, which expires with WithTTL
+// like the state.
+func (r *RedisStorage) Append(ctx context.Context, record fsm.TransitionRecord) error {
+	payload, err := json.Marshal(record.Payload)
+	if err != nil {
+		return fmt.Errorf("redis: encoding payload of event '%s': %w", record.Event, err)
+	}
+	b, err := json.Marshal(transitionEntry{
+		Timestamp: record.Timestamp,
+		Event:     record.Event,
+		Payload:   payload,
+		From:      record.From,
+		To:        record.To,
+		Actor:     record.Actor,
+	})
+	if err != nil {
+		return err
+	}
+
+	key := r.historyKey(record.EntityID)
+	pipe := r.client.TxPipeline()
+	pipe.RPush(ctx, key, b)
+	if r.ttl > 0 {
+		pipe.Expire(ctx, key, r.ttl)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *RedisStorage) History(ctx context.Context, entityID string) ([]fsm.TransitionRecord, error) {
+	values, err := r.client.LRange(ctx, r.historyKey(entityID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]fsm.TransitionRecord, 0, len(values))
+	for _, v := range values {
+		var entry transitionEntry
+		if err := json.Unmarshal([]byte(v), &entry); err != nil {
+			return records, err
+		}
+		var payload any
+		if len(entry.Payload) > 0 {
+			if err := json.Unmarshal(entry.Payload, &payload); err != nil {
+				return records, err
+			}
+		}
+		records = append(records, fsm.TransitionRecord{
+			EntityID:  entityID,
+			Timestamp: entry.Timestamp,
+			Event:     entry.Event,
+			Payload:   payload,
+			From:      entry.From,
+			To:        entry.To,
+			Actor:     entry.Actor,
+		})
+	}
+	return records, nil
+}
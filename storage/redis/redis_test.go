@@ -2,12 +2,14 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/rluders/gofsm/fsm"
 	tc "github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
 )
@@ -173,3 +175,313 @@ func TestRedisStorage_Lock_ExpiredWithoutUnlock(t *testing.T) {
 		t.Fatalf("expected reacquire lock after TTL expiration, got error: %v", err)
 	}
 }
+
+func TestRedisStorage_GetState_NotFound(t *testing.T) {
+	client, cleanup := setupRedisContainer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	storage := NewRedisStorage(client, WithPrefix("fsm"))
+
+	_, err := storage.GetState(ctx, "entity-missing")
+	if !errors.Is(err, fsm.ErrEntityNotFound) {
+		t.Fatalf("expected ErrEntityNotFound, got %v", err)
+	}
+}
+
+func TestRedisStorage_Complete_TTL(t *testing.T) {
+	client, cleanup := setupRedisContainer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	entityID := "entity-completed"
+	storage := NewRedisStorage(client, WithPrefix("fsm"), WithCompletedTTL(time.Minute))
+
+	if err := storage.SetState(ctx, entityID, "completed"); err != nil {
+		t.Fatalf("failed to set state: %v", err)
+	}
+	if err := storage.Complete(ctx, entityID, "completed"); err != nil {
+		t.Fatalf("failed to complete entity: %v", err)
+	}
+
+	ttl, err := client.TTL(ctx, storage.key(entityID)).Result()
+	if err != nil {
+		t.Fatalf("failed to read ttl: %v", err)
+	}
+	if ttl <= 0 || ttl > time.Minute {
+		t.Errorf("expected ttl within one minute, got %s", ttl)
+	}
+}
+
+func TestRedisStorage_Timers(t *testing.T) {
+	client, cleanup := setupRedisContainer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	storage := NewRedisStorage(client, WithPrefix("fsm"))
+	_ = client.Del(ctx, storage.timersKey())
+
+	now := time.Now()
+	due := fsm.Timer{EntityID: "entity-1", State: "running", Event: "timeout", FireAt: now.Add(-time.Second)}
+	later := fsm.Timer{EntityID: "entity-2", State: "running", Event: "timeout", FireAt: now.Add(time.Hour)}
+	cancelled := fsm.Timer{EntityID: "entity-3", State: "running", Event: "timeout", FireAt: now.Add(-time.Second)}
+
+	for _, timer := range []fsm.Timer{due, later, cancelled} {
+		if err := storage.Schedule(ctx, timer); err != nil {
+			t.Fatalf("failed to schedule timer: %v", err)
+		}
+	}
+	if err := storage.Cancel(ctx, cancelled); err != nil {
+		t.Fatalf("failed to cancel timer: %v", err)
+	}
+
+	timers, err := storage.Due(ctx, now)
+	if err != nil {
+		t.Fatalf("failed to read due timers: %v", err)
+	}
+	if len(timers) != 1 || timers[0].EntityID != "entity-1" || timers[0].Event != "timeout" {
+		t.Fatalf("unexpected due timers: %+v", timers)
+	}
+
+	timers, err = storage.Due(ctx, now)
+	if err != nil {
+		t.Fatalf("failed to read due timers: %v", err)
+	}
+	if len(timers) != 0 {
+		t.Errorf("expected due timers to be claimed once, got %+v", timers)
+	}
+}
+
+func TestRedisStorage_EntityNamedTimers(t *testing.T) {
+	client, cleanup := setupRedisContainer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	storage := NewRedisStorage(client, WithPrefix("fsm"))
+
+	timer := fsm.Timer{EntityID: "entity-1", State: "running", Event: "timeout", FireAt: time.Now().Add(-time.Second)}
+	if err := storage.Schedule(ctx, timer); err != nil {
+		t.Fatalf("failed to schedule timer: %v", err)
+	}
+	if err := storage.SetState(ctx, "timers", "running"); err != nil {
+		t.Fatalf("failed to set state: %v", err)
+	}
+
+	if state, err := storage.GetState(ctx, "timers"); err != nil || state != "running" {
+		t.Errorf("unexpected state %s: %v", state, err)
+	}
+	if timers, err := storage.Due(ctx, time.Now()); err != nil || len(timers) != 1 {
+		t.Errorf("expected the timer to survive, got %+v, %v", timers, err)
+	}
+}
+
+func TestRedisStorage_LegacyKey(t *testing.T) {
+	client, cleanup := setupRedisContainer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	entityID := "entity-legacy"
+	storage := NewRedisStorage(client, WithPrefix("fsm"))
+	if err := client.Set(ctx, "fsm:"+entityID, "running", 0).Err(); err != nil {
+		t.Fatalf("failed to write legacy state: %v", err)
+	}
+
+	state, version, err := storage.GetStateVersion(ctx, entityID)
+	if err != nil || state != "running" || version != 0 {
+		t.Fatalf("expected to read the legacy state, got %s at version %d: %v", state, version, err)
+	}
+	if _, err := storage.CompareAndSetState(ctx, entityID, "done", version); err != nil {
+		t.Fatalf("failed to compare and set: %v", err)
+	}
+
+	if n := client.Exists(ctx, "fsm:"+entityID).Val(); n != 0 {
+		t.Errorf("expected the legacy key to be removed")
+	}
+	if state, err := storage.GetState(ctx, entityID); err != nil || state != "done" {
+		t.Errorf("unexpected state %s: %v", state, err)
+	}
+}
+
+func TestRedisStorage_History(t *testing.T) {
+	client, cleanup := setupRedisContainer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	entityID := "entity-history"
+	storage := NewRedisStorage(client, WithPrefix("fsm"))
+	_ = client.Del(ctx, storage.historyKey(entityID))
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	records := []fsm.TransitionRecord{
+		{EntityID: entityID, Timestamp: now, Event: "fsm.init", To: "pending"},
+		{EntityID: entityID, Timestamp: now, Event: "start_scan", Payload: map[string]any{"targets": float64(2)}, From: "pending", To: "running", Actor: "alice"},
+	}
+	for _, record := range records {
+		if err := storage.Append(ctx, record); err != nil {
+			t.Fatalf("failed to append record: %v", err)
+		}
+	}
+
+	history, err := storage.History(ctx, entityID)
+	if err != nil {
+		t.Fatalf("failed to read history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 records, got %+v", history)
+	}
+	got := history[1]
+	if got.Event != "start_scan" || got.From != "pending" || got.To != "running" || got.Actor != "alice" || !got.Timestamp.Equal(now) {
+		t.Errorf("unexpected record: %+v", got)
+	}
+	if payload, ok := got.Payload.(map[string]any); !ok || payload["targets"] != float64(2) {
+		t.Errorf("expected payload to round-trip, got %#v", got.Payload)
+	}
+}
+
+func TestRedisStorage_StateData(t *testing.T) {
+	client, cleanup := setupRedisContainer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	entityID := "entity-data"
+	storage := NewRedisStorage(client, WithPrefix("fsm"))
+	_ = client.Del(ctx, storage.key(entityID), storage.dataKey(entityID))
+
+	if _, _, err := storage.GetStateData(ctx, entityID); !errors.Is(err, fsm.ErrEntityNotFound) {
+		t.Fatalf("expected ErrEntityNotFound, got %v", err)
+	}
+
+	if err := storage.SetStateData(ctx, entityID, "running", []byte(`{"jobs":2}`)); err != nil {
+		t.Fatalf("failed to set state data: %v", err)
+	}
+	state, data, err := storage.GetStateData(ctx, entityID)
+	if err != nil {
+		t.Fatalf("failed to get state data: %v", err)
+	}
+	if state != "running" || string(data) != `{"jobs":2}` {
+		t.Errorf("unexpected state data: %s %s", state, data)
+	}
+
+	if err := storage.SetState(ctx, entityID, "completed"); err != nil {
+		t.Fatalf("failed to set state: %v", err)
+	}
+	if _, data, _ := storage.GetStateData(ctx, entityID); string(data) != `{"jobs":2}` {
+		t.Errorf("expected SetState to keep the data, got %s", data)
+	}
+}
+
+func TestRedisStorage_CompareAndSetState(t *testing.T) {
+	client, cleanup := setupRedisContainer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	entityID := "entity-cas"
+	storage := NewRedisStorage(client, WithPrefix("fsm"))
+	_ = client.Del(ctx, storage.key(entityID), storage.versionKey(entityID), storage.dataKey(entityID))
+
+	version, err := storage.CompareAndSetState(ctx, entityID, "pending", 0)
+	if err != nil {
+		t.Fatalf("failed to create entity: %v", err)
+	}
+	if _, err := storage.CompareAndSetState(ctx, entityID, "pending", 0); !errors.Is(err, fsm.ErrVersionConflict) {
+		t.Errorf("expected ErrVersionConflict creating an existing entity, got %v", err)
+	}
+
+	if err := storage.SetState(ctx, entityID, "pending"); err != nil {
+		t.Fatalf("failed to set state: %v", err)
+	}
+	if _, err := storage.CompareAndSetState(ctx, entityID, "running", version); !errors.Is(err, fsm.ErrVersionConflict) {
+		t.Errorf("expected ErrVersionConflict after SetState, got %v", err)
+	}
+
+	state, version, err := storage.GetStateVersion(ctx, entityID)
+	if err != nil || state != "pending" {
+		t.Fatalf("unexpected state %s: %v", state, err)
+	}
+	next, err := storage.CompareAndSetStateData(ctx, entityID, "running", []byte(`{"jobs":2}`), version)
+	if err != nil {
+		t.Fatalf("failed to compare and set: %v", err)
+	}
+	if next != version+1 {
+		t.Errorf("expected version %d, got %d", version+1, next)
+	}
+
+	state, data, version, err := storage.GetStateDataVersion(ctx, entityID)
+	if err != nil || state != "running" || string(data) != `{"jobs":2}` || version != next {
+		t.Errorf("unexpected state data: %s %s %d %v", state, data, version, err)
+	}
+}
+
+func TestRedisStorage_HistoryStates(t *testing.T) {
+	client, cleanup := setupRedisContainer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	entityID := "entity-history-states"
+	storage := NewRedisStorage(client, WithPrefix("fsm"), WithCompletedTTL(time.Minute))
+	_ = client.Del(ctx, storage.historyStatesKey(entityID))
+
+	if err := storage.SetHistoryStates(ctx, entityID, map[string]string{"active": "active/running"}); err != nil {
+		t.Fatalf("failed to set history states: %v", err)
+	}
+	if err := storage.SetHistoryStates(ctx, entityID, map[string]string{"scan": "scan/done"}); err != nil {
+		t.Fatalf("failed to set history states: %v", err)
+	}
+	states, err := storage.GetHistoryStates(ctx, entityID)
+	if err != nil {
+		t.Fatalf("failed to get history states: %v", err)
+	}
+	if len(states) != 2 || states["active"] != "active/running" || states["scan"] != "scan/done" {
+		t.Errorf("unexpected history states: %v", states)
+	}
+	if _, err := storage.GetState(ctx, entityID+":history:active"); !errors.Is(err, fsm.ErrEntityNotFound) {
+		t.Errorf("expected history not to be stored as an entity, got %v", err)
+	}
+
+	if err := storage.Complete(ctx, entityID, "done"); err != nil {
+		t.Fatalf("failed to complete: %v", err)
+	}
+	if ttl := client.TTL(ctx, storage.historyStatesKey(entityID)).Val(); ttl <= 0 {
+		t.Errorf("expected history states to expire with the entity, got TTL %s", ttl)
+	}
+}
+
+func TestRedisStorage_AuxiliaryKeys(t *testing.T) {
+	client, cleanup := setupRedisContainer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	entityID := "entity-aux"
+	storage := NewRedisStorage(client, WithPrefix("fsm"))
+
+	if err := storage.SetStateData(ctx, entityID, "running", []byte(`{"jobs":2}`)); err != nil {
+		t.Fatalf("failed to set state data: %v", err)
+	}
+	if err := storage.SetHistoryStates(ctx, entityID, map[string]string{"active": "active/running"}); err != nil {
+		t.Fatalf("failed to set history states: %v", err)
+	}
+	if err := storage.Append(ctx, fsm.TransitionRecord{EntityID: entityID, Event: "fsm.init", To: "running"}); err != nil {
+		t.Fatalf("failed to append record: %v", err)
+	}
+
+	for _, namespace := range []string{"version", "data", "history", "transitions"} {
+		other := namespace + ":" + entityID
+		if _, err := storage.GetState(ctx, other); !errors.Is(err, fsm.ErrEntityNotFound) {
+			t.Errorf("expected '%s' not to exist, got %v", other, err)
+		}
+		if err := storage.SetState(ctx, other, "pending"); err != nil {
+			t.Fatalf("failed to set state of '%s': %v", other, err)
+		}
+	}
+
+	state, data, version, err := storage.GetStateDataVersion(ctx, entityID)
+	if err != nil || state != "running" || string(data) != `{"jobs":2}` || version != 1 {
+		t.Errorf("unexpected state data: %s %s %d %v", state, data, version, err)
+	}
+	if states, err := storage.GetHistoryStates(ctx, entityID); err != nil || states["active"] != "active/running" {
+		t.Errorf("unexpected history states: %v, %v", states, err)
+	}
+	if records, err := storage.History(ctx, entityID); err != nil || len(records) != 1 {
+		t.Errorf("unexpected history: %+v, %v", records, err)
+	}
+}